@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/core/pkg/db"
+	"github.com/invenlore/identity.service/internal/repository"
+	"github.com/invenlore/identity.service/internal/service"
+	"github.com/invenlore/identity.service/internal/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// RewrapKeys reseals every signing private key under the current key encryption key and exits.
+// It is for KEK rotation: run it after switching AUTH_KEY_ENCRYPTION_KEY_ID so the previous KEK
+// can be removed from the keyring. Plaintext keys are sealed by the auth keys encryption migration;
+// it only leaves behind keys stored before the first KEK was added, which this also seals.
+func RewrapKeys() {
+	loggerEntry := logrus.WithField("scope", "rewrap-keys")
+
+	// Exit non-zero only after the deferred disconnect has run.
+	failed := false
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	cfg, err := config.Config()
+	if err != nil {
+		loggerEntry.Fatalf("failed to load configuration: %v", err)
+	}
+
+	identityCfg, err := settings.Load()
+	if err != nil {
+		loggerEntry.Fatalf("failed to load identity settings: %v", err)
+	}
+
+	mongoCfg := cfg.GetConfig().GetMongoConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	mongoClient, err := db.MongoDBConnect(ctx, mongoCfg)
+	if err != nil {
+		loggerEntry.Errorf("MongoDB connect failed: %v", err)
+		failed = true
+
+		return
+	}
+
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = mongoClient.Disconnect(stopCtx)
+	}()
+
+	vault, err := service.NewAuthKeyVault(&identityCfg.Auth)
+	if err != nil {
+		loggerEntry.Errorf("auth key vault init failed: %v", err)
+		failed = true
+
+		return
+	}
+
+	authRepo := repository.NewIdentityAuthRepository(mongoClient, mongoCfg)
+
+	count, err := service.RewrapAuthKeys(ctx, authRepo, vault)
+	if err != nil {
+		loggerEntry.Errorf("rewrap failed after %d keys: %v", count, err)
+		failed = true
+
+		return
+	}
+
+	loggerEntry.WithField("kek_id", identityCfg.Auth.KeyEncryptionKeyID).Infof("rewrapped %d keys", count)
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,8 +15,10 @@ import (
 	"github.com/invenlore/core/pkg/db"
 	"github.com/invenlore/core/pkg/migrator"
 	"github.com/invenlore/identity.service/internal/migrations"
+	"github.com/invenlore/identity.service/internal/notify"
 	"github.com/invenlore/identity.service/internal/repository"
 	"github.com/invenlore/identity.service/internal/service"
+	"github.com/invenlore/identity.service/internal/settings"
 	"github.com/invenlore/identity.service/internal/transport"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
@@ -31,6 +34,11 @@ func Start() {
 		loggerEntry.Fatalf("failed to load configuration: %v", err)
 	}
 
+	identityCfg, err := settings.Load()
+	if err != nil {
+		loggerEntry.Fatalf("failed to load identity settings: %v", err)
+	}
+
 	appCfg := cfg.GetConfig()
 	mongoCfg := appCfg.GetMongoConfig()
 	authCfg := appCfg.GetAuthConfig()
@@ -40,6 +48,11 @@ func Start() {
 
 	g, ctx := errgroup.WithContext(baseCtx)
 
+	authKeyVault, err := service.NewAuthKeyVault(&identityCfg.Auth)
+	if err != nil {
+		loggerEntry.Fatalf("auth key vault init failed: %v", err)
+	}
+
 	mongoClient, err := db.MongoDBConnect(ctx, mongoCfg)
 	if err != nil {
 		loggerEntry.Fatalf("MongoDB connect failed: %v", err)
@@ -71,7 +84,7 @@ func Start() {
 	})
 
 	g.Go(func() error {
-		if err := mgr.Run(ctx, migrations.List()); err != nil {
+		if err := mgr.Run(ctx, migrations.List(authKeyVault)); err != nil {
 			loggerEntry.Errorf("MongoDB migrations failed, keeping service in degraded mode: %v", err)
 			mongoReadiness.CloseGate("MongoDB migrations failed: " + err.Error())
 
@@ -96,23 +109,82 @@ func Start() {
 		return mongoClient.Disconnect(stopCtx)
 	})
 
+	throttleRepo := repository.NewIdentityLoginThrottleRepository(mongoClient, mongoCfg)
+	loginThrottler := service.NewLoginThrottler(throttleRepo, &identityCfg.Auth)
 	adminRepo := repository.NewIdentityAdminRepository(mongoClient, mongoCfg)
-	adminSvc := service.NewIdentityAdminService(adminRepo)
+	userBriefSvc := service.NewIdentityUserBriefService(adminRepo)
+	adminSvc := service.NewIdentityAdminService(adminRepo, userBriefSvc, loginThrottler, authCfg)
 	authRepo := repository.NewIdentityAuthRepository(mongoClient, mongoCfg)
-	authSvc := service.NewIdentityAuthService(authRepo, authCfg)
+
+	var keyProvider service.KeyProvider
+	switch identityCfg.Auth.KeyProvider {
+	case settings.KeyProviderFile:
+		keyProvider, err = service.NewFileKeyProvider(
+			identityCfg.Auth.KeyFileDir,
+			identityCfg.Auth.KeyFileActiveKid,
+			identityCfg.Auth.KeyFileReloadInterval,
+			logrus.WithField("scope", "file-key-provider"),
+		)
+		if err != nil {
+			loggerEntry.Fatalf("file key provider init failed: %v", err)
+		}
+	default:
+		if !authKeyVault.Enabled() {
+			loggerEntry.Warn("no key encryption key configured, signing private keys are stored in plaintext")
+		} else if plaintext, err := authRepo.CountPlaintextAuthKeys(ctx); err != nil {
+			loggerEntry.Warnf("plaintext auth keys check failed: %v", err)
+		} else if plaintext > 0 {
+			// The encryption migration only seals what exists when it first runs with a KEK.
+			loggerEntry.Warnf("%d signing private keys are stored in plaintext, run rewrap-keys to seal them", plaintext)
+		}
+
+		keyProvider = service.NewMongoKeyProvider(authRepo, authKeyVault, identityCfg.Auth.SigningKeyAlg)
+	}
+
+	loggerEntry.Infof("signing keys provided by %s", identityCfg.Auth.KeyProvider)
+
+	publicKeyCache := service.NewPublicKeyCache(keyProvider, authCfg.KeyRotationTickInterval)
+
+	securityEvents := service.NewLogSecurityEventEmitter(logrus.WithField("scope", "security"))
+
+	notifier, err := notify.New(identityCfg.Notify.Driver, identityCfg.Notify.OutboxPath, logrus.WithField("scope", "notify"))
+	if err != nil {
+		loggerEntry.Fatalf("notifier init failed: %v", err)
+	}
+
+	var breachedPasswords *service.BreachedPasswords
+	if path := identityCfg.Auth.PasswordBreachedListPath; path != "" {
+		breachedPasswords, err = service.LoadBreachedPasswords(path)
+		if err != nil {
+			loggerEntry.Fatalf("breached password list load failed: %v", err)
+		}
+
+		loggerEntry.Infof("breached password list loaded: %d hashes", breachedPasswords.Len())
+	}
+
+	passwordPolicy := service.NewPasswordPolicy(&identityCfg.Auth, breachedPasswords)
+	passwordHashLimiter := service.NewPasswordHashLimiter(&identityCfg.Auth)
+	expvar.Publish("password_hashing", expvar.Func(func() any { return passwordHashLimiter.Stats() }))
+
+	authSvc := service.NewIdentityAuthService(authRepo, userBriefSvc, publicKeyCache, keyProvider, securityEvents, notifier, loginThrottler, passwordPolicy, passwordHashLimiter, authCfg, &identityCfg.Auth)
+	policyRepo := repository.NewIdentityPolicyRepository(mongoClient, mongoCfg)
+	policySvc := service.NewIdentityPolicyService(policyRepo)
 
 	authKeyRotator := service.NewAuthKeyRotator(
 		mongoClient.Database(mongoCfg.DatabaseName),
 		authRepo,
+		publicKeyCache,
+		authKeyVault,
 		owner,
 		service.AuthKeyRotatorConfig{
 			RotationInterval: authCfg.KeyRotationInterval,
 			RetireAfter:      authCfg.KeyRetireAfter,
+			Alg:              identityCfg.Auth.SigningKeyAlg,
 		},
 		logrus.WithField("scope", "auth-key-rotation"),
 	)
 
-	grpcSrv, grpcLn, err := transport.StartGRPCServer(appCfg.GetGRPCConfig(), adminSvc, authSvc, mongoReadiness)
+	grpcSrv, grpcLn, err := transport.StartGRPCServer(appCfg.GetGRPCConfig(), adminSvc, authSvc, policySvc, userBriefSvc, mongoReadiness, identityCfg.Auth.TrustedProxyPrefixes)
 	if err != nil {
 		loggerEntry.Fatalf("gRPC server init failed: %v", err)
 	}
@@ -144,19 +216,22 @@ func Start() {
 		return nil
 	})
 
-	g.Go(func() error {
-		ticker := time.NewTicker(authCfg.KeyRotationTickInterval)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return nil
-			case <-ticker.C:
-				authKeyRotator.Tick(ctx)
+	// Only keys in auth_keys are rotated here; other providers rotate keys outside the service.
+	if identityCfg.Auth.KeyProvider == settings.KeyProviderMongo {
+		g.Go(func() error {
+			ticker := time.NewTicker(authCfg.KeyRotationTickInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-ticker.C:
+					authKeyRotator.Tick(ctx)
+				}
 			}
-		}
-	})
+		})
+	}
 
 	g.Go(func() error {
 		<-ctx.Done()
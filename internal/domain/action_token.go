@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ActionTokenPurpose string
+
+const (
+	ActionTokenPurposePasswordReset     ActionTokenPurpose = "password_reset"
+	ActionTokenPurposeEmailVerification ActionTokenPurpose = "email_verification"
+	ActionTokenPurposeMFAChallenge      ActionTokenPurpose = "mfa_challenge"
+	ActionTokenPurposeWebAuthnRegister  ActionTokenPurpose = "webauthn_registration"
+	ActionTokenPurposeWebAuthnLogin     ActionTokenPurpose = "webauthn_login"
+)
+
+// ActionTokenMaxAttempts is how many failed uses a token survives before it stops matching.
+const ActionTokenMaxAttempts = 5
+
+// ActionToken is a single-use, expiring token sent to the user out of band; only its hash is stored.
+type ActionToken struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Purpose   ActionTokenPurpose `bson:"purpose" json:"purpose"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	IPAddress string             `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty" json:"used_at,omitempty"`
+	Attempts  int                `bson:"attempts,omitempty" json:"attempts,omitempty"`
+}
@@ -14,14 +14,18 @@ const (
 	AuthKeyStatusRevoked  AuthKeyStatus = "revoked"
 )
 
+// AuthKey holds its private PEM either in PrivateKeyPEM (stored before a key encryption key was
+// configured) or sealed in PrivateKeyCiphertext under the KEK named by KEKID.
 type AuthKey struct {
-	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Kid           string             `bson:"kid" json:"kid"`
-	Alg           string             `bson:"alg" json:"alg"`
-	Use           string             `bson:"use" json:"use"`
-	PrivateKeyPEM string             `bson:"private_key_pem" json:"-"`
-	PublicKeyPEM  string             `bson:"public_key_pem" json:"-"`
-	Status        AuthKeyStatus      `bson:"status" json:"status"`
-	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
-	RotatedAt     *time.Time         `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
+	Id                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kid                  string             `bson:"kid" json:"kid"`
+	Alg                  string             `bson:"alg" json:"alg"`
+	Use                  string             `bson:"use" json:"use"`
+	PrivateKeyPEM        string             `bson:"private_key_pem,omitempty" json:"-"`
+	PrivateKeyCiphertext string             `bson:"private_key_ciphertext,omitempty" json:"-"`
+	KEKID                string             `bson:"kek_id,omitempty" json:"kek_id,omitempty"`
+	PublicKeyPEM         string             `bson:"public_key_pem" json:"-"`
+	Status               AuthKeyStatus      `bson:"status" json:"status"`
+	CreatedAt            time.Time          `bson:"created_at" json:"created_at"`
+	RotatedAt            *time.Time         `bson:"rotated_at,omitempty" json:"rotated_at,omitempty"`
 }
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoginThrottleKind string
+
+const (
+	LoginThrottleKindEmail LoginThrottleKind = "email"
+	LoginThrottleKindIP    LoginThrottleKind = "ip"
+)
+
+// LoginThrottle counts recent failed logins for one email or one client IP. Documents expire
+// (TTL on expires_at) once no failure has been seen for the tracking window.
+type LoginThrottle struct {
+	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Key           string             `bson:"key" json:"key"`
+	Kind          LoginThrottleKind  `bson:"kind" json:"kind"`
+	Value         string             `bson:"value" json:"value"`
+	Failures      int64              `bson:"failures" json:"failures"`
+	FirstFailedAt time.Time          `bson:"first_failed_at" json:"first_failed_at"`
+	LastFailedAt  time.Time          `bson:"last_failed_at" json:"last_failed_at"`
+	LockedUntil   *time.Time         `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+func LoginThrottleKey(kind LoginThrottleKind, value string) string {
+	return string(kind) + ":" + value
+}
@@ -6,14 +6,57 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// RefreshSessionPreviousHashesLimit caps how many rotated token hashes a session remembers for reuse detection.
+const RefreshSessionPreviousHashesLimit = 20
+
+// Authentication method references (RFC 8176) recorded on sessions and emitted as the amr claim.
+const (
+	AuthMethodPassword = "pwd"
+	AuthMethodOTP      = "otp"
+	AuthMethodMFA      = "mfa"
+	AuthMethodHardware = "hwk"
+	AuthMethodPresence = "user"
+	// AuthMethodRecovery is not registered in RFC 8176; it marks sessions opened with a recovery code.
+	AuthMethodRecovery = "recovery"
+)
+
+const (
+	SessionRevokeReasonLogout            = "logout"
+	SessionRevokeReasonRefreshTokenReuse = "refresh_token_reuse"
+	SessionRevokeReasonUser              = "revoked_by_user"
+	SessionRevokeReasonAdmin             = "revoked_by_admin"
+	SessionRevokeReasonUserDeleted       = "user_deleted"
+	SessionRevokeReasonUserDisabled      = "user_disabled"
+	SessionRevokeReasonUserSuspended     = "user_suspended"
+	SessionRevokeReasonUserMissing       = "user_missing"
+	SessionRevokeReasonPasswordReset     = "password_reset"
+	SessionRevokeReasonPasswordChange    = "password_changed"
+)
+
 type RefreshSession struct {
-	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	SessionID        string             `bson:"session_id" json:"session_id"`
-	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
-	RefreshTokenHash string             `bson:"refresh_token_hash" json:"-"`
-	UserAgent        string             `bson:"user_agent" json:"user_agent"`
-	IPAddress        string             `bson:"ip_address" json:"ip_address"`
-	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
-	ExpiresAt        time.Time          `bson:"expires_at" json:"expires_at"`
-	RevokedAt        *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	Id                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionID           string             `bson:"session_id" json:"session_id"`
+	UserID              primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RefreshTokenHash    string             `bson:"refresh_token_hash" json:"-"`
+	PreviousTokenHashes []string           `bson:"previous_token_hashes,omitempty" json:"-"`
+	Generation          int64              `bson:"generation" json:"generation"`
+	UserAgent           string             `bson:"user_agent" json:"user_agent"`
+	IPAddress           string             `bson:"ip_address" json:"ip_address"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt           time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	ExpiresAt           time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt           *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedReason       string             `bson:"revoked_reason,omitempty" json:"revoked_reason,omitempty"`
+	Grace               *RefreshGrace      `bson:"grace,omitempty" json:"-"`
+	AMR                 []string           `bson:"amr,omitempty" json:"amr,omitempty"`
+	Recovery            bool               `bson:"recovery,omitempty" json:"recovery,omitempty"`
+}
+
+// RefreshGrace lets the immediately previous refresh token replay the pair issued by the last
+// rotation until Until, so concurrent refreshes from the same client do not fail.
+// SealedResponse is encrypted with a key derived from the previous token, which is never stored.
+type RefreshGrace struct {
+	PreviousTokenHash string    `bson:"previous_token_hash" json:"-"`
+	SealedResponse    string    `bson:"sealed_response" json:"-"`
+	Until             time.Time `bson:"until" json:"until"`
 }
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevokedTokenKind string
+
+// A jti entry denies one access token; a sid entry denies every access token of a refresh session.
+const (
+	RevokedTokenKindJTI RevokedTokenKind = "jti"
+	RevokedTokenKindSID RevokedTokenKind = "sid"
+)
+
+// RevokedToken denies access tokens by jti or by sid (refresh session ID) until ExpiresAt,
+// which is never earlier than the expiry of the last access token that could carry the value.
+type RevokedToken struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind      RevokedTokenKind   `bson:"kind" json:"kind"`
+	Value     string             `bson:"value" json:"value"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	RevokedAt time.Time          `bson:"revoked_at" json:"revoked_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+}
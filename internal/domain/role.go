@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PermissionEffect string
+
+const (
+	PermissionEffectAllow PermissionEffect = "allow"
+	PermissionEffectDeny  PermissionEffect = "deny"
+)
+
+const PermissionWildcard = "*"
+
+type Permission struct {
+	Resource string           `bson:"resource" json:"resource"`
+	Action   string           `bson:"action" json:"action"`
+	Effect   PermissionEffect `bson:"effect,omitempty" json:"effect,omitempty"`
+}
+
+type Role struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Permissions []Permission       `bson:"permissions" json:"permissions"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -6,12 +6,83 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+	UserStatusDisabled  UserStatus = "disabled"
+)
+
 type User struct {
-	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name         string             `bson:"name" json:"name"`
-	Email        string             `bson:"email" json:"email"`
-	Roles        []string           `bson:"roles" json:"roles"`
-	PasswordHash string             `bson:"password_hash" json:"-"`
-	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+	Id              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	Email           string             `bson:"email" json:"email"`
+	Roles           []string           `bson:"roles" json:"roles"`
+	PasswordHash    string             `bson:"password_hash" json:"-"`
+	Status          UserStatus         `bson:"status,omitempty" json:"status,omitempty"`
+	StatusReason    string             `bson:"status_reason,omitempty" json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time         `bson:"status_changed_at,omitempty" json:"status_changed_at,omitempty"`
+	SuspendedUntil  *time.Time         `bson:"suspended_until,omitempty" json:"suspended_until,omitempty"`
+	EmailVerifiedAt *time.Time         `bson:"email_verified_at,omitempty" json:"email_verified_at,omitempty"`
+	MFA             *UserMFA           `bson:"mfa,omitempty" json:"-"`
+	RecoveryCodes   []RecoveryCode     `bson:"recovery_codes,omitempty" json:"-"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt       time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// EffectiveStatus treats a missing status as active and an expired suspension as lifted.
+func (u *User) EffectiveStatus(now time.Time) UserStatus {
+	switch u.Status {
+	case "", UserStatusActive:
+		return UserStatusActive
+	case UserStatusSuspended:
+		if u.SuspendedUntil != nil && !now.Before(*u.SuspendedUntil) {
+			return UserStatusActive
+		}
+	}
+
+	return u.Status
+}
+
+// UserMFA holds second-factor state; secrets are stored sealed, never in plaintext.
+type UserMFA struct {
+	TOTPSecret        string     `bson:"totp_secret,omitempty"`
+	TOTPPendingSecret string     `bson:"totp_pending_secret,omitempty"`
+	TOTPEnabledAt     *time.Time `bson:"totp_enabled_at,omitempty"`
+	TOTPLastStep      int64      `bson:"totp_last_step,omitempty"`
+}
+
+func (u *User) TOTPEnabled() bool {
+	return u.MFA != nil && u.MFA.TOTPEnabledAt != nil && u.MFA.TOTPSecret != ""
+}
+
+// RecoveryCode is a one-time break-glass factor. ID is the public prefix of the code and is used to
+// find the single hash to verify, so a login attempt costs one argon2 run instead of one per code.
+type RecoveryCode struct {
+	ID        string     `bson:"id"`
+	Hash      string     `bson:"hash"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+func (u *User) RemainingRecoveryCodes() int {
+	remaining := 0
+	for _, code := range u.RecoveryCodes {
+		if code.UsedAt == nil {
+			remaining++
+		}
+	}
+
+	return remaining
+}
+
+type UserStatusUpdate struct {
+	Status         UserStatus
+	Reason         string
+	SuspendedUntil *time.Time
+}
+
+type UserProfileUpdate struct {
+	Name string
 }
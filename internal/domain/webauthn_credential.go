@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebAuthnCredential is a registered passkey / security key. CredentialID is base64url encoded
+// and PublicKey holds the COSE_Key bytes exactly as the authenticator produced them.
+type WebAuthnCredential struct {
+	Id                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID `bson:"user_id" json:"user_id"`
+	CredentialID      string             `bson:"credential_id" json:"credential_id"`
+	PublicKey         []byte             `bson:"public_key" json:"-"`
+	Algorithm         int64              `bson:"algorithm" json:"algorithm"`
+	AttestationFormat string             `bson:"attestation_format" json:"attestation_format"`
+	AAGUID            string             `bson:"aaguid,omitempty" json:"aaguid,omitempty"`
+	SignCount         int64              `bson:"sign_count" json:"sign_count"`
+	Transports        []string           `bson:"transports,omitempty" json:"transports,omitempty"`
+	Name              string             `bson:"name,omitempty" json:"name,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt        *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	// CloneDetectedAt is set when an assertion arrives with a non-increasing sign count;
+	// the credential is refused from then on.
+	CloneDetectedAt *time.Time `bson:"clone_detected_at,omitempty" json:"clone_detected_at,omitempty"`
+}
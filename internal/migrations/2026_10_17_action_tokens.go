@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	Migration_20261017_ActionTokensCollection_1 = migrator.Migration{
+		Version: 15,
+		Name:    "action_tokens: create collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: "action_tokens"}})
+			if err != nil {
+				return err
+			}
+
+			if len(names) > 0 {
+				return nil
+			}
+
+			return db.CreateCollection(ctx, "action_tokens")
+		},
+	}
+
+	Migration_20261017_ActionTokensIndexes_1 = migrator.Migration{
+		Version: 16,
+		Name:    "action_tokens: indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("action_tokens")
+			models := []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "token_hash", Value: 1}},
+					Options: options.Index().SetUnique(true).SetName("uniq_token_hash"),
+				},
+				{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}},
+					Options: options.Index().SetName("idx_user_id_purpose"),
+				},
+				{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0).SetName("ttl_expires_at"),
+				},
+			}
+
+			_, err := col.Indexes().CreateMany(ctx, models)
+			return err
+		},
+	}
+)
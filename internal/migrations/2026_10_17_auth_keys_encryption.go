@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuthKeySealer seals signing private keys under the current key encryption key. The keyring is
+// part of the identity settings, so cmd passes the vault in rather than migrations loading it.
+type AuthKeySealer interface {
+	Enabled() bool
+	Rewrap(*domain.AuthKey) (bool, error)
+}
+
+// Migration_20261017_AuthKeysEncryption_1 seals private keys stored in plaintext. Without a
+// configured KEK there is nothing to seal with, so it only logs why it was skipped; keys stored
+// before a KEK is added are sealed with the rewrap-keys command.
+func Migration_20261017_AuthKeysEncryption_1(keys AuthKeySealer) migrator.Migration {
+	return migrator.Migration{
+		Version: 21,
+		Name:    "auth_keys: encrypt private keys",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if !keys.Enabled() {
+				logrus.WithField("scope", "migrations").Warn("auth keys encryption skipped: no key encryption key configured")
+				return nil
+			}
+
+			col := db.Collection("auth_keys")
+			plaintext := bson.M{"private_key_pem": bson.M{"$exists": true, "$ne": ""}}
+
+			cur, err := col.Find(ctx, plaintext)
+			if err != nil {
+				return fmt.Errorf("auth keys encryption migration failed: %w", err)
+			}
+
+			defer func() { _ = cur.Close(context.Background()) }()
+
+			for cur.Next(ctx) {
+				var key domain.AuthKey
+				if err := cur.Decode(&key); err != nil {
+					return fmt.Errorf("auth keys encryption migration failed: %w", err)
+				}
+
+				changed, err := keys.Rewrap(&key)
+				if err != nil {
+					return fmt.Errorf("auth keys encryption migration failed for %q: %w", key.Kid, err)
+				}
+
+				if !changed {
+					continue
+				}
+
+				update := bson.M{
+					"$set":   bson.M{"private_key_ciphertext": key.PrivateKeyCiphertext, "kek_id": key.KEKID},
+					"$unset": bson.M{"private_key_pem": ""},
+				}
+
+				if _, err := col.UpdateOne(ctx, bson.M{"_id": key.Id, "private_key_pem": bson.M{"$exists": true}}, update); err != nil {
+					return fmt.Errorf("auth keys encryption migration failed for %q: %w", key.Kid, err)
+				}
+			}
+
+			if err := cur.Err(); err != nil {
+				return fmt.Errorf("auth keys encryption migration failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
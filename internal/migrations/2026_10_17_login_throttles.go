@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	Migration_20261017_LoginThrottlesCollection_1 = migrator.Migration{
+		Version: 19,
+		Name:    "login_throttles: create collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: "login_throttles"}})
+			if err != nil {
+				return err
+			}
+
+			if len(names) > 0 {
+				return nil
+			}
+
+			return db.CreateCollection(ctx, "login_throttles")
+		},
+	}
+
+	Migration_20261017_LoginThrottlesIndexes_1 = migrator.Migration{
+		Version: 20,
+		Name:    "login_throttles: indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("login_throttles")
+			models := []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "key", Value: 1}},
+					Options: options.Index().SetUnique(true).SetName("uniq_key"),
+				},
+				{
+					Keys:    bson.D{{Key: "locked_until", Value: 1}},
+					Options: options.Index().SetName("idx_locked_until"),
+				},
+				{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0).SetName("ttl_expires_at"),
+				},
+			}
+
+			_, err := col.Indexes().CreateMany(ctx, models)
+			return err
+		},
+	}
+)
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	Migration_20261017_RevokedTokensCollection_1 = migrator.Migration{
+		Version: 13,
+		Name:    "revoked_tokens: create collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: "revoked_tokens"}})
+			if err != nil {
+				return err
+			}
+
+			if len(names) > 0 {
+				return nil
+			}
+
+			return db.CreateCollection(ctx, "revoked_tokens")
+		},
+	}
+
+	Migration_20261017_RevokedTokensIndexes_1 = migrator.Migration{
+		Version: 14,
+		Name:    "revoked_tokens: indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("revoked_tokens")
+			models := []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "kind", Value: 1}, {Key: "value", Value: 1}},
+					Options: options.Index().SetUnique(true).SetName("uniq_kind_value"),
+				},
+				{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetExpireAfterSeconds(0).SetName("ttl_expires_at"),
+				},
+			}
+
+			_, err := col.Indexes().CreateMany(ctx, models)
+			return err
+		},
+	}
+)
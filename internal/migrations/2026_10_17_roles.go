@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	Migration_20261017_RolesCollection_1 = migrator.Migration{
+		Version: 9,
+		Name:    "roles: create collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: "roles"}})
+			if err != nil {
+				return err
+			}
+
+			if len(names) > 0 {
+				return nil
+			}
+
+			return db.CreateCollection(ctx, "roles")
+		},
+	}
+
+	Migration_20261017_RolesIndexes_1 = migrator.Migration{
+		Version: 10,
+		Name:    "roles: indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("roles")
+			model := mongo.IndexModel{
+				Keys:    bson.D{{Key: "name", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("uniq_name"),
+			}
+
+			_, err := col.Indexes().CreateOne(ctx, model)
+			return err
+		},
+	}
+
+	Migration_20261017_RolesDefaults_1 = migrator.Migration{
+		Version: 11,
+		Name:    "roles: default roles",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("roles")
+			now := time.Now().UTC()
+
+			defaults := []domain.Role{
+				{
+					Name:        "user",
+					Description: "Registered user",
+					Permissions: []domain.Permission{
+						{Resource: "profile", Action: "read"},
+						{Resource: "profile", Action: "update"},
+					},
+				},
+				{
+					Name:        "admin",
+					Description: "Full access",
+					Permissions: []domain.Permission{
+						{Resource: domain.PermissionWildcard, Action: domain.PermissionWildcard},
+					},
+				},
+			}
+
+			for _, role := range defaults {
+				update := bson.M{
+					"$setOnInsert": bson.M{
+						"name":        role.Name,
+						"description": role.Description,
+						"permissions": role.Permissions,
+						"created_at":  now,
+						"updated_at":  now,
+					},
+				}
+
+				_, err := col.UpdateOne(ctx, bson.M{"name": role.Name}, update, options.Update().SetUpsert(true))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+)
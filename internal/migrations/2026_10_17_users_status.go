@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	Migration_20261017_UsersStatus_1 = migrator.Migration{
+		Version: 12,
+		Name:    "users: account status",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("users")
+
+			active := bson.M{"$set": bson.M{"status": domain.UserStatusActive}}
+
+			if _, err := col.UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}}, active); err != nil {
+				return fmt.Errorf("users status migration failed: %w", err)
+			}
+
+			return nil
+		},
+	}
+)
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/migrator"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	Migration_20261017_WebAuthnCredentialsCollection_1 = migrator.Migration{
+		Version: 17,
+		Name:    "webauthn_credentials: create collection",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			names, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: "webauthn_credentials"}})
+			if err != nil {
+				return err
+			}
+
+			if len(names) > 0 {
+				return nil
+			}
+
+			return db.CreateCollection(ctx, "webauthn_credentials")
+		},
+	}
+
+	Migration_20261017_WebAuthnCredentialsIndexes_1 = migrator.Migration{
+		Version: 18,
+		Name:    "webauthn_credentials: indexes",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			col := db.Collection("webauthn_credentials")
+			models := []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "credential_id", Value: 1}},
+					Options: options.Index().SetUnique(true).SetName("uniq_credential_id"),
+				},
+				{
+					Keys:    bson.D{{Key: "user_id", Value: 1}},
+					Options: options.Index().SetName("idx_user_id"),
+				},
+			}
+
+			_, err := col.Indexes().CreateMany(ctx, models)
+			return err
+		},
+	}
+)
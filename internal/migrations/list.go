@@ -4,7 +4,8 @@ import (
 	"github.com/invenlore/core/pkg/migrator"
 )
 
-func List() []migrator.Migration {
+// List takes the auth key vault for the private key encryption migration.
+func List(authKeys AuthKeySealer) []migrator.Migration {
 	return []migrator.Migration{
 		Migration_20260114_UsersCollection_1,
 		Migration_20260114_UsersUniqueEmailIndex_1,
@@ -14,5 +15,18 @@ func List() []migrator.Migration {
 		Migration_20260124_RefreshSessionsIndexes_1,
 		Migration_20260124_UsersAuthFields_1,
 		Migration_20260125_AuthKeysRotatedAtIndex_1,
+		Migration_20261017_RolesCollection_1,
+		Migration_20261017_RolesIndexes_1,
+		Migration_20261017_RolesDefaults_1,
+		Migration_20261017_UsersStatus_1,
+		Migration_20261017_RevokedTokensCollection_1,
+		Migration_20261017_RevokedTokensIndexes_1,
+		Migration_20261017_ActionTokensCollection_1,
+		Migration_20261017_ActionTokensIndexes_1,
+		Migration_20261017_WebAuthnCredentialsCollection_1,
+		Migration_20261017_WebAuthnCredentialsIndexes_1,
+		Migration_20261017_LoginThrottlesCollection_1,
+		Migration_20261017_LoginThrottlesIndexes_1,
+		Migration_20261017_AuthKeysEncryption_1(authKeys),
 	}
 }
@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DriverLog  = "log"
+	DriverFile = "file"
+)
+
+func New(driver, outboxPath string, logger *logrus.Entry) (Notifier, error) {
+	switch driver {
+	case "", DriverLog:
+		return NewLogNotifier(logger), nil
+	case DriverFile:
+		if outboxPath == "" {
+			return nil, fmt.Errorf("notify: outbox path is required for %q driver", DriverFile)
+		}
+
+		return NewFileOutbox(outboxPath), nil
+	default:
+		return nil, fmt.Errorf("notify: unknown driver %q", driver)
+	}
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type logNotifier struct {
+	logger *logrus.Entry
+}
+
+// NewLogNotifier writes messages to the log; meant for local development only,
+// as the payload usually contains one-time tokens.
+func NewLogNotifier(logger *logrus.Entry) Notifier {
+	if logger == nil {
+		logger = logrus.WithField("scope", "notify")
+	}
+
+	return &logNotifier{logger: logger}
+}
+
+func (n *logNotifier) Send(ctx context.Context, msg Message) error {
+	n.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"to":       msg.To,
+		"subject":  msg.Subject,
+		"template": msg.Template,
+		"data":     msg.Data,
+	}).Info("notification")
+
+	return nil
+}
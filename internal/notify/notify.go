@@ -0,0 +1,17 @@
+package notify
+
+import (
+	"context"
+)
+
+type Message struct {
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Template string            `json:"template"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// Notifier delivers user-facing messages (password reset links, verification codes, ...).
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileOutbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+type outboxRecord struct {
+	Message
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewFileOutbox appends every message as a JSON line to path, so local setups can
+// pick up reset links without a mail server.
+func NewFileOutbox(path string) Notifier {
+	return &fileOutbox{path: path}
+}
+
+func (o *fileOutbox) Send(ctx context.Context, msg Message) error {
+	line, err := json.Marshal(outboxRecord{Message: msg, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	f, err := os.OpenFile(o.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open outbox %q failed: %w", o.path, err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -3,30 +3,45 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/invenlore/core/pkg/config"
 	"github.com/invenlore/identity.service/internal/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type identityAdminRepository struct {
-	usersCol *mongo.Collection
-	cfg      *config.MongoConfig
+	usersCol    *mongo.Collection
+	sessionsCol *mongo.Collection
+	revokedCol  *mongo.Collection
+	cfg         *config.MongoConfig
 }
 
 type IdentityAdminRepository interface {
 	InsertUser(context.Context, *domain.User) (primitive.ObjectID, error)
 	FindOneUser(context.Context, primitive.ObjectID) (*domain.User, error)
 	DeleteOneUser(context.Context, primitive.ObjectID) (int64, error)
+	UpdateUserStatus(context.Context, primitive.ObjectID, domain.UserStatusUpdate, time.Time) (*domain.User, error)
 	ListUsers(ctx context.Context) ([]*domain.User, error)
+	FindUsersByIDs(context.Context, []primitive.ObjectID) ([]*domain.User, error)
+	ListActiveUserRefreshSessions(context.Context, primitive.ObjectID, time.Time) ([]*domain.RefreshSession, error)
+	RevokeUserRefreshSession(context.Context, primitive.ObjectID, string, time.Time, string) error
+	RevokeUserRefreshSessions(context.Context, primitive.ObjectID, string, time.Time, string) ([]string, error)
+	InsertRevokedTokens(context.Context, []*domain.RevokedToken) error
+	CountLegacyPasswordHashes(context.Context) (int64, error)
 }
 
 func NewIdentityAdminRepository(db *mongo.Client, cfg *config.MongoConfig) IdentityAdminRepository {
+	database := db.Database(cfg.DatabaseName)
+
 	return &identityAdminRepository{
-		usersCol: db.Database(cfg.DatabaseName).Collection("users"),
-		cfg:      cfg,
+		usersCol:    database.Collection("users"),
+		sessionsCol: database.Collection("refresh_sessions"),
+		revokedCol:  database.Collection("revoked_tokens"),
+		cfg:         cfg,
 	}
 }
 
@@ -75,6 +90,35 @@ func (r *identityAdminRepository) DeleteOneUser(ctx context.Context, id primitiv
 	return result.DeletedCount, nil
 }
 
+func (r *identityAdminRepository) UpdateUserStatus(ctx context.Context, id primitive.ObjectID, status domain.UserStatusUpdate, changedAt time.Time) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	set := bson.M{
+		"status":            status.Status,
+		"status_reason":     status.Reason,
+		"status_changed_at": changedAt,
+		"updated_at":        changedAt,
+	}
+	update := bson.M{"$set": set}
+
+	if status.SuspendedUntil != nil {
+		set["suspended_until"] = *status.SuspendedUntil
+	} else {
+		update["$unset"] = bson.M{"suspended_until": ""}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var user domain.User
+	if err := r.usersCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 func (r *identityAdminRepository) ListUsers(ctx context.Context) ([]*domain.User, error) {
 	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
 	defer cancel()
@@ -104,3 +148,66 @@ func (r *identityAdminRepository) ListUsers(ctx context.Context) ([]*domain.User
 
 	return users, nil
 }
+
+func (r *identityAdminRepository) FindUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": bson.M{"$in": ids}}
+
+	cur, err := r.usersCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	users := make([]*domain.User, 0, len(ids))
+
+	for cur.Next(ctx) {
+		var u domain.User
+
+		if err := cur.Decode(&u); err != nil {
+			return nil, err
+		}
+
+		users = append(users, &u)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *identityAdminRepository) ListActiveUserRefreshSessions(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]*domain.RefreshSession, error) {
+	return listActiveUserRefreshSessions(ctx, r.sessionsCol, r.cfg, userID, now)
+}
+
+func (r *identityAdminRepository) RevokeUserRefreshSession(ctx context.Context, userID primitive.ObjectID, sessionID string, revokedAt time.Time, reason string) error {
+	return revokeUserRefreshSession(ctx, r.sessionsCol, r.cfg, userID, sessionID, revokedAt, reason)
+}
+
+func (r *identityAdminRepository) RevokeUserRefreshSessions(ctx context.Context, userID primitive.ObjectID, exceptSessionID string, revokedAt time.Time, reason string) ([]string, error) {
+	return revokeUserRefreshSessions(ctx, r.sessionsCol, r.cfg, userID, exceptSessionID, revokedAt, reason)
+}
+
+func (r *identityAdminRepository) InsertRevokedTokens(ctx context.Context, tokens []*domain.RevokedToken) error {
+	return insertRevokedTokens(ctx, r.revokedCol, r.cfg, tokens)
+}
+
+// CountLegacyPasswordHashes counts users with a password hash outside the argon2id PHC format.
+// Users created without a password are not counted.
+func (r *identityAdminRepository) CountLegacyPasswordHashes(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"password_hash": bson.M{
+		"$exists": true,
+		"$ne":     "",
+		"$not":    primitive.Regex{Pattern: `^\$argon2id\$`},
+	}}
+
+	return r.usersCol.CountDocuments(ctx, filter)
+}
@@ -18,20 +18,50 @@ type IdentityAuthRepository interface {
 	FindActiveAuthKey(context.Context) (*domain.AuthKey, error)
 	ListActivePublicKeys(context.Context) ([]*domain.AuthKey, error)
 	UpdateAuthKeyStatus(context.Context, primitive.ObjectID, domain.AuthKeyStatus, *time.Time) error
+	ListAuthKeysWithPrivateKey(context.Context) ([]*domain.AuthKey, error)
+	CountPlaintextAuthKeys(context.Context) (int64, error)
+	UpdateAuthKeyPrivateKey(context.Context, primitive.ObjectID, string, string, string) error
 	RevokeRetiringBefore(context.Context, time.Time) (int64, error)
 	InsertUserCredentials(context.Context, *domain.User) (primitive.ObjectID, error)
 	FindUserByEmail(context.Context, string) (*domain.User, error)
 	FindUserByID(context.Context, primitive.ObjectID) (*domain.User, error)
+	UpdateUserProfile(context.Context, primitive.ObjectID, domain.UserProfileUpdate, time.Time) (*domain.User, error)
+	UpdateUserPasswordHash(context.Context, primitive.ObjectID, string, time.Time) error
+	ReplaceUserPasswordHash(context.Context, primitive.ObjectID, string, string) error
+	MarkUserEmailVerified(context.Context, primitive.ObjectID, time.Time) error
+	SetUserPendingTOTPSecret(context.Context, primitive.ObjectID, string, time.Time) error
+	EnableUserTOTP(context.Context, primitive.ObjectID, string, int64, time.Time) error
+	RecordUserTOTPStep(context.Context, primitive.ObjectID, int64) error
+	ReplaceUserRecoveryCodes(context.Context, primitive.ObjectID, []domain.RecoveryCode, time.Time) error
+	UseUserRecoveryCode(context.Context, primitive.ObjectID, string, time.Time) error
 	InsertRefreshSession(context.Context, *domain.RefreshSession) error
 	FindRefreshSession(context.Context, string) (*domain.RefreshSession, error)
-	RevokeRefreshSession(context.Context, string, time.Time) error
-	RotateRefreshSession(context.Context, string, string, time.Time, time.Time) error
+	ListActiveUserRefreshSessions(context.Context, primitive.ObjectID, time.Time) ([]*domain.RefreshSession, error)
+	RevokeUserRefreshSession(context.Context, primitive.ObjectID, string, time.Time, string) error
+	RevokeRefreshSession(context.Context, string, time.Time, string) error
+	RevokeUserRefreshSessions(context.Context, primitive.ObjectID, string, time.Time, string) ([]string, error)
+	InsertRevokedTokens(context.Context, []*domain.RevokedToken) error
+	IsTokenRevoked(context.Context, string, string) (bool, error)
+	InsertActionToken(context.Context, *domain.ActionToken) error
+	ConsumeActionToken(context.Context, domain.ActionTokenPurpose, string, time.Time) (*domain.ActionToken, error)
+	FindActionToken(context.Context, domain.ActionTokenPurpose, string, time.Time) (*domain.ActionToken, error)
+	RecordActionTokenFailure(context.Context, primitive.ObjectID) error
+	DeleteUserActionTokens(context.Context, primitive.ObjectID, domain.ActionTokenPurpose) error
+	RotateRefreshSession(context.Context, string, string, string, time.Time, time.Time, *domain.RefreshGrace) error
+	InsertWebAuthnCredential(context.Context, *domain.WebAuthnCredential) error
+	FindWebAuthnCredential(context.Context, string) (*domain.WebAuthnCredential, error)
+	ListUserWebAuthnCredentials(context.Context, primitive.ObjectID) ([]*domain.WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(context.Context, primitive.ObjectID, int64, int64, time.Time) error
+	MarkWebAuthnCredentialCloned(context.Context, primitive.ObjectID, time.Time) error
 }
 
 type identityAuthRepository struct {
 	usersCol    *mongo.Collection
 	keysCol     *mongo.Collection
 	sessionsCol *mongo.Collection
+	revokedCol  *mongo.Collection
+	actionsCol  *mongo.Collection
+	webauthnCol *mongo.Collection
 	cfg         *config.MongoConfig
 }
 
@@ -42,6 +72,9 @@ func NewIdentityAuthRepository(db *mongo.Client, cfg *config.MongoConfig) Identi
 		usersCol:    database.Collection("users"),
 		keysCol:     database.Collection("auth_keys"),
 		sessionsCol: database.Collection("refresh_sessions"),
+		revokedCol:  database.Collection("revoked_tokens"),
+		actionsCol:  database.Collection("action_tokens"),
+		webauthnCol: database.Collection("webauthn_credentials"),
 		cfg:         cfg,
 	}
 }
@@ -122,6 +155,79 @@ func (r *identityAuthRepository) UpdateAuthKeyStatus(ctx context.Context, id pri
 	return nil
 }
 
+// ListAuthKeysWithPrivateKey returns keys of every status that still hold private material,
+// sealed or not; revoked keys included, since a leaked one can still sign tokens for old kids.
+func (r *identityAuthRepository) ListAuthKeysWithPrivateKey(ctx context.Context) ([]*domain.AuthKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"private_key_pem": bson.M{"$exists": true, "$ne": ""}},
+		bson.M{"private_key_ciphertext": bson.M{"$exists": true, "$ne": ""}},
+	}}
+
+	cur, err := r.keysCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	keys := make([]*domain.AuthKey, 0)
+
+	for cur.Next(ctx) {
+		var key domain.AuthKey
+
+		if err := cur.Decode(&key); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// CountPlaintextAuthKeys counts keys whose private PEM is still stored unsealed.
+func (r *identityAuthRepository) CountPlaintextAuthKeys(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	return r.keysCol.CountDocuments(ctx, bson.M{"private_key_pem": bson.M{"$exists": true, "$ne": ""}})
+}
+
+// UpdateAuthKeyPrivateKey stores a sealed private key and drops any plaintext copy. It only
+// matches while the document is still sealed with previousKEKID ("" meaning plaintext).
+func (r *identityAuthRepository) UpdateAuthKeyPrivateKey(ctx context.Context, id primitive.ObjectID, ciphertext, kekID, previousKEKID string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "kek_id": previousKEKID}
+	if previousKEKID == "" {
+		filter["kek_id"] = bson.M{"$exists": false}
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"private_key_ciphertext": ciphertext, "kek_id": kekID},
+		"$unset": bson.M{"private_key_pem": ""},
+	}
+
+	result, err := r.keysCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
 func (r *identityAuthRepository) RevokeRetiringBefore(ctx context.Context, cutoff time.Time) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
 	defer cancel()
@@ -186,6 +292,63 @@ func (r *identityAuthRepository) FindUserByID(ctx context.Context, id primitive.
 	return &user, nil
 }
 
+func (r *identityAuthRepository) UpdateUserProfile(ctx context.Context, id primitive.ObjectID, profile domain.UserProfileUpdate, updatedAt time.Time) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"name": profile.Name, "updated_at": updatedAt}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var user domain.User
+	if err := r.usersCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *identityAuthRepository) UpdateUserPasswordHash(ctx context.Context, id primitive.ObjectID, passwordHash string, updatedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"password_hash": passwordHash, "updated_at": updatedAt}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// ReplaceUserPasswordHash swaps the hash only while it still equals previousHash, so a parameter
+// upgrade cannot undo a password change that landed in between. updated_at is left alone because
+// the password itself did not change.
+func (r *identityAuthRepository) ReplaceUserPasswordHash(ctx context.Context, id primitive.ObjectID, previousHash, passwordHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "password_hash": previousHash}
+	update := bson.M{"$set": bson.M{"password_hash": passwordHash}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
 func (r *identityAuthRepository) InsertRefreshSession(ctx context.Context, session *domain.RefreshSession) error {
 	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
 	defer cancel()
@@ -208,12 +371,20 @@ func (r *identityAuthRepository) FindRefreshSession(ctx context.Context, session
 	return &session, nil
 }
 
-func (r *identityAuthRepository) RevokeRefreshSession(ctx context.Context, sessionID string, revokedAt time.Time) error {
+func (r *identityAuthRepository) ListActiveUserRefreshSessions(ctx context.Context, userID primitive.ObjectID, now time.Time) ([]*domain.RefreshSession, error) {
+	return listActiveUserRefreshSessions(ctx, r.sessionsCol, r.cfg, userID, now)
+}
+
+func (r *identityAuthRepository) RevokeUserRefreshSession(ctx context.Context, userID primitive.ObjectID, sessionID string, revokedAt time.Time, reason string) error {
+	return revokeUserRefreshSession(ctx, r.sessionsCol, r.cfg, userID, sessionID, revokedAt, reason)
+}
+
+func (r *identityAuthRepository) RevokeRefreshSession(ctx context.Context, sessionID string, revokedAt time.Time, reason string) error {
 	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
 	defer cancel()
 
 	filter := bson.M{"session_id": sessionID}
-	update := bson.M{"$set": bson.M{"revoked_at": revokedAt}}
+	update := bson.M{"$set": bson.M{"revoked_at": revokedAt, "revoked_reason": reason}}
 
 	result, err := r.sessionsCol.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -227,12 +398,32 @@ func (r *identityAuthRepository) RevokeRefreshSession(ctx context.Context, sessi
 	return nil
 }
 
-func (r *identityAuthRepository) RotateRefreshSession(ctx context.Context, sessionID string, tokenHash string, expiresAt time.Time, updatedAt time.Time) error {
+func (r *identityAuthRepository) RevokeUserRefreshSessions(ctx context.Context, userID primitive.ObjectID, exceptSessionID string, revokedAt time.Time, reason string) ([]string, error) {
+	return revokeUserRefreshSessions(ctx, r.sessionsCol, r.cfg, userID, exceptSessionID, revokedAt, reason)
+}
+
+// RotateRefreshSession swaps the token hash only if it still equals oldTokenHash; mongo.ErrNoDocuments
+// means the session was rotated (or revoked) concurrently.
+func (r *identityAuthRepository) RotateRefreshSession(ctx context.Context, sessionID string, oldTokenHash string, tokenHash string, expiresAt time.Time, updatedAt time.Time, grace *domain.RefreshGrace) error {
 	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
 	defer cancel()
 
-	filter := bson.M{"session_id": sessionID}
-	update := bson.M{"$set": bson.M{"refresh_token_hash": tokenHash, "expires_at": expiresAt, "updated_at": updatedAt}}
+	filter := bson.M{"session_id": sessionID, "refresh_token_hash": oldTokenHash, "revoked_at": nil}
+	set := bson.M{"refresh_token_hash": tokenHash, "expires_at": expiresAt, "updated_at": updatedAt}
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"generation": 1},
+		"$push": bson.M{"previous_token_hashes": bson.M{
+			"$each":  bson.A{oldTokenHash},
+			"$slice": -domain.RefreshSessionPreviousHashesLimit,
+		}},
+	}
+
+	if grace != nil {
+		set["grace"] = grace
+	} else {
+		update["$unset"] = bson.M{"grace": ""}
+	}
 
 	result, err := r.sessionsCol.UpdateOne(ctx, filter, update)
 	if err != nil {
@@ -245,3 +436,231 @@ func (r *identityAuthRepository) RotateRefreshSession(ctx context.Context, sessi
 
 	return nil
 }
+
+func (r *identityAuthRepository) InsertRevokedTokens(ctx context.Context, tokens []*domain.RevokedToken) error {
+	return insertRevokedTokens(ctx, r.revokedCol, r.cfg, tokens)
+}
+
+// IsTokenRevoked reports whether an access token with the given jti or sid is on the denylist.
+func (r *identityAuthRepository) IsTokenRevoked(ctx context.Context, jti string, sid string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	or := bson.A{}
+	if jti != "" {
+		or = append(or, bson.M{"kind": domain.RevokedTokenKindJTI, "value": jti})
+	}
+
+	if sid != "" {
+		or = append(or, bson.M{"kind": domain.RevokedTokenKindSID, "value": sid})
+	}
+
+	if len(or) == 0 {
+		return false, nil
+	}
+
+	filter := bson.M{"$or": or, "expires_at": bson.M{"$gt": time.Now().UTC()}}
+
+	count, err := r.revokedCol.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (r *identityAuthRepository) MarkUserEmailVerified(ctx context.Context, id primitive.ObjectID, verifiedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"email_verified_at": verifiedAt, "updated_at": verifiedAt}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (r *identityAuthRepository) SetUserPendingTOTPSecret(ctx context.Context, id primitive.ObjectID, sealedSecret string, updatedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"mfa.totp_pending_secret": sealedSecret, "updated_at": updatedAt}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// EnableUserTOTP promotes the pending secret only if it is still the one the code was checked against.
+func (r *identityAuthRepository) EnableUserTOTP(ctx context.Context, id primitive.ObjectID, sealedSecret string, step int64, enabledAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "mfa.totp_pending_secret": sealedSecret}
+	update := bson.M{
+		"$set": bson.M{
+			"mfa.totp_secret":     sealedSecret,
+			"mfa.totp_enabled_at": enabledAt,
+			"mfa.totp_last_step":  step,
+			"updated_at":          enabledAt,
+		},
+		"$unset": bson.M{"mfa.totp_pending_secret": ""},
+	}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// RecordUserTOTPStep advances the last used step; ErrNoDocuments means the step was already used.
+func (r *identityAuthRepository) RecordUserTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "mfa.totp_last_step": bson.M{"$not": bson.M{"$gte": step}}}
+	update := bson.M{"$set": bson.M{"mfa.totp_last_step": step}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (r *identityAuthRepository) ReplaceUserRecoveryCodes(ctx context.Context, id primitive.ObjectID, codes []domain.RecoveryCode, updatedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"recovery_codes": codes, "updated_at": updatedAt}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// UseUserRecoveryCode marks an unused code as used; ErrNoDocuments means it was already spent.
+func (r *identityAuthRepository) UseUserRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeID string, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"_id":            userID,
+		"recovery_codes": bson.M{"$elemMatch": bson.M{"id": codeID, "used_at": nil}},
+	}
+	update := bson.M{"$set": bson.M{"recovery_codes.$.used_at": usedAt}}
+
+	result, err := r.usersCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (r *identityAuthRepository) InsertActionToken(ctx context.Context, token *domain.ActionToken) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	_, err := r.actionsCol.InsertOne(ctx, token)
+	return err
+}
+
+// ConsumeActionToken atomically marks an unused, unexpired token as used and returns it.
+func (r *identityAuthRepository) ConsumeActionToken(ctx context.Context, purpose domain.ActionTokenPurpose, tokenHash string, now time.Time) (*domain.ActionToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"purpose":    purpose,
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+		"attempts":   bson.M{"$not": bson.M{"$gte": domain.ActionTokenMaxAttempts}},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var token domain.ActionToken
+	if err := r.actionsCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// FindActionToken returns a usable token without consuming it, for flows that allow retries.
+func (r *identityAuthRepository) FindActionToken(ctx context.Context, purpose domain.ActionTokenPurpose, tokenHash string, now time.Time) (*domain.ActionToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"purpose":    purpose,
+		"token_hash": tokenHash,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+		"attempts":   bson.M{"$not": bson.M{"$gte": domain.ActionTokenMaxAttempts}},
+	}
+
+	var token domain.ActionToken
+	if err := r.actionsCol.FindOne(ctx, filter).Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *identityAuthRepository) RecordActionTokenFailure(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	_, err := r.actionsCol.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"attempts": 1}})
+	return err
+}
+
+func (r *identityAuthRepository) DeleteUserActionTokens(ctx context.Context, userID primitive.ObjectID, purpose domain.ActionTokenPurpose) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	_, err := r.actionsCol.DeleteMany(ctx, bson.M{"user_id": userID, "purpose": purpose})
+	return err
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IdentityLoginThrottleRepository interface {
+	FindLoginThrottles(context.Context, []string) ([]*domain.LoginThrottle, error)
+	RecordLoginFailure(context.Context, domain.LoginThrottleKind, string, time.Time, time.Time) (*domain.LoginThrottle, error)
+	LockLoginThrottle(context.Context, string, time.Time) error
+	DeleteLoginThrottles(context.Context, []string) (int64, error)
+	ListLoginThrottles(context.Context, bool, time.Time, int64) ([]*domain.LoginThrottle, error)
+}
+
+type identityLoginThrottleRepository struct {
+	throttlesCol *mongo.Collection
+	cfg          *config.MongoConfig
+}
+
+func NewIdentityLoginThrottleRepository(db *mongo.Client, cfg *config.MongoConfig) IdentityLoginThrottleRepository {
+	database := db.Database(cfg.DatabaseName)
+
+	return &identityLoginThrottleRepository{
+		throttlesCol: database.Collection("login_throttles"),
+		cfg:          cfg,
+	}
+}
+
+func (r *identityLoginThrottleRepository) FindLoginThrottles(ctx context.Context, keys []string) ([]*domain.LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	cur, err := r.throttlesCol.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	throttles := make([]*domain.LoginThrottle, 0, len(keys))
+
+	for cur.Next(ctx) {
+		var throttle domain.LoginThrottle
+		if err := cur.Decode(&throttle); err != nil {
+			return nil, err
+		}
+
+		throttles = append(throttles, &throttle)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+// RecordLoginFailure increments the failure counter for kind/value, creating it if needed,
+// and returns the updated document.
+func (r *identityLoginThrottleRepository) RecordLoginFailure(ctx context.Context, kind domain.LoginThrottleKind, value string, failedAt, expiresAt time.Time) (*domain.LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"key": domain.LoginThrottleKey(kind, value)}
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failed_at": failedAt, "expires_at": expiresAt},
+		"$setOnInsert": bson.M{
+			"kind":            kind,
+			"value":           value,
+			"first_failed_at": failedAt,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var throttle domain.LoginThrottle
+	if err := r.throttlesCol.FindOneAndUpdate(ctx, filter, update, opts).Decode(&throttle); err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// LockLoginThrottle extends the lock; a shorter lock never replaces a longer one.
+func (r *identityLoginThrottleRepository) LockLoginThrottle(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"key": key}
+	update := bson.M{"$max": bson.M{"locked_until": until, "expires_at": until}}
+
+	_, err := r.throttlesCol.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func (r *identityLoginThrottleRepository) DeleteLoginThrottles(ctx context.Context, keys []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	result, err := r.throttlesCol.DeleteMany(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+func (r *identityLoginThrottleRepository) ListLoginThrottles(ctx context.Context, lockedOnly bool, now time.Time, limit int64) ([]*domain.LoginThrottle, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if lockedOnly {
+		filter["locked_until"] = bson.M{"$gt": now}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_failed_at", Value: -1}}).SetLimit(limit)
+
+	cur, err := r.throttlesCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	throttles := make([]*domain.LoginThrottle, 0)
+
+	for cur.Next(ctx) {
+		var throttle domain.LoginThrottle
+		if err := cur.Decode(&throttle); err != nil {
+			return nil, err
+		}
+
+		throttles = append(throttles, &throttle)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IdentityPolicyRepository interface {
+	FindUserByID(context.Context, primitive.ObjectID) (*domain.User, error)
+	FindRolesByNames(context.Context, []string) ([]*domain.Role, error)
+}
+
+type identityPolicyRepository struct {
+	usersCol *mongo.Collection
+	rolesCol *mongo.Collection
+	cfg      *config.MongoConfig
+}
+
+func NewIdentityPolicyRepository(db *mongo.Client, cfg *config.MongoConfig) IdentityPolicyRepository {
+	database := db.Database(cfg.DatabaseName)
+
+	return &identityPolicyRepository{
+		usersCol: database.Collection("users"),
+		rolesCol: database.Collection("roles"),
+		cfg:      cfg,
+	}
+}
+
+func (r *identityPolicyRepository) FindUserByID(ctx context.Context, id primitive.ObjectID) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	var user domain.User
+
+	if err := r.usersCol.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *identityPolicyRepository) FindRolesByNames(ctx context.Context, names []string) ([]*domain.Role, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	roles := make([]*domain.Role, 0, len(names))
+	if len(names) == 0 {
+		return roles, nil
+	}
+
+	cur, err := r.rolesCol.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	for cur.Next(ctx) {
+		var role domain.Role
+
+		if err := cur.Decode(&role); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, &role)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
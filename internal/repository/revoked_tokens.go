@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func insertRevokedTokens(ctx context.Context, col *mongo.Collection, cfg *config.MongoConfig, tokens []*domain.RevokedToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.OperationTimeout)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(tokens))
+	for _, token := range tokens {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"kind": token.Kind, "value": token.Value}).
+			SetUpdate(bson.M{
+				"$setOnInsert": bson.M{"revoked_at": token.RevokedAt, "reason": token.Reason},
+				"$max":         bson.M{"expires_at": token.ExpiresAt},
+			}).
+			SetUpsert(true))
+	}
+
+	_, err := col.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
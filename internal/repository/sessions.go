@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Session queries shared by the auth (self-service) and admin repositories; all of them go through idx_user_id.
+
+func listActiveUserRefreshSessions(ctx context.Context, col *mongo.Collection, cfg *config.MongoConfig, userID primitive.ObjectID, now time.Time) ([]*domain.RefreshSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userID,
+		"revoked_at": nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cur, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	sessions := make([]*domain.RefreshSession, 0)
+
+	for cur.Next(ctx) {
+		var session domain.RefreshSession
+
+		if err := cur.Decode(&session); err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func revokeUserRefreshSession(ctx context.Context, col *mongo.Collection, cfg *config.MongoConfig, userID primitive.ObjectID, sessionID string, revokedAt time.Time, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "session_id": sessionID, "revoked_at": nil}
+	update := bson.M{"$set": bson.M{"revoked_at": revokedAt, "revoked_reason": reason}}
+
+	result, err := col.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// revokeUserRefreshSessions revokes every not yet revoked session of the user, except exceptSessionID
+// when it is not empty, and returns the IDs of the sessions it revoked.
+func revokeUserRefreshSessions(ctx context.Context, col *mongo.Collection, cfg *config.MongoConfig, userID primitive.ObjectID, exceptSessionID string, revokedAt time.Time, reason string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID, "revoked_at": nil}
+	if exceptSessionID != "" {
+		filter["session_id"] = bson.M{"$ne": exceptSessionID}
+	}
+
+	opts := options.Find().SetProjection(bson.M{"session_id": 1})
+
+	cur, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	sessionIDs := make([]string, 0)
+
+	for cur.Next(ctx) {
+		var session domain.RefreshSession
+
+		if err := cur.Decode(&session); err != nil {
+			return nil, err
+		}
+
+		sessionIDs = append(sessionIDs, session.SessionID)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(sessionIDs) == 0 {
+		return sessionIDs, nil
+	}
+
+	filter = bson.M{"session_id": bson.M{"$in": sessionIDs}, "revoked_at": nil}
+	update := bson.M{"$set": bson.M{"revoked_at": revokedAt, "revoked_reason": reason}}
+
+	if _, err := col.UpdateMany(ctx, filter, update); err != nil {
+		return nil, err
+	}
+
+	return sessionIDs, nil
+}
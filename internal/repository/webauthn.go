@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *identityAuthRepository) InsertWebAuthnCredential(ctx context.Context, credential *domain.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	_, err := r.webauthnCol.InsertOne(ctx, credential)
+	return err
+}
+
+func (r *identityAuthRepository) FindWebAuthnCredential(ctx context.Context, credentialID string) (*domain.WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	var credential domain.WebAuthnCredential
+	if err := r.webauthnCol.FindOne(ctx, bson.M{"credential_id": credentialID}).Decode(&credential); err != nil {
+		return nil, err
+	}
+
+	return &credential, nil
+}
+
+func (r *identityAuthRepository) ListUserWebAuthnCredentials(ctx context.Context, userID primitive.ObjectID) ([]*domain.WebAuthnCredential, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	cur, err := r.webauthnCol.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	credentials := make([]*domain.WebAuthnCredential, 0)
+
+	for cur.Next(ctx) {
+		var credential domain.WebAuthnCredential
+		if err := cur.Decode(&credential); err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, &credential)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+// UpdateWebAuthnSignCount records a successful assertion. The update only applies while the stored
+// counter is still previousCount, so two assertions racing with the same counter cannot both pass.
+func (r *identityAuthRepository) UpdateWebAuthnSignCount(ctx context.Context, id primitive.ObjectID, previousCount, signCount int64, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "sign_count": previousCount, "clone_detected_at": nil}
+	update := bson.M{"$set": bson.M{"sign_count": signCount, "last_used_at": usedAt}}
+
+	result, err := r.webauthnCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+func (r *identityAuthRepository) MarkWebAuthnCredentialCloned(ctx context.Context, id primitive.ObjectID, detectedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.OperationTimeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "clone_detected_at": nil}
+	update := bson.M{"$set": bson.M{"clone_detected_at": detectedAt}}
+
+	_, err := r.webauthnCol.UpdateOne(ctx, filter, update)
+	return err
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+)
+
+// AccountStatusError is returned by Login and Refresh for users that are not active,
+// so the transport can attach a machine-readable reason instead of "invalid credentials".
+type AccountStatusError struct {
+	Status         domain.UserStatus
+	Reason         string
+	SuspendedUntil *time.Time
+}
+
+func (e *AccountStatusError) Error() string {
+	return fmt.Sprintf("account %s", e.Status)
+}
+
+func checkAccountStatus(user *domain.User) error {
+	status := user.EffectiveStatus(time.Now())
+	if status == domain.UserStatusActive {
+		return nil
+	}
+
+	return &AccountStatusError{
+		Status:         status,
+		Reason:         user.StatusReason,
+		SuspendedUntil: user.SuspendedUntil,
+	}
+}
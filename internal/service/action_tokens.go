@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/url"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/notify"
+	"github.com/sirupsen/logrus"
+)
+
+const notificationTimeout = 10 * time.Second
+
+// issueActionToken replaces any outstanding token of the same purpose for the user and
+// returns the plaintext token; only its hash is persisted.
+func (s *identityAuthService) issueActionToken(ctx context.Context, user *domain.User, purpose domain.ActionTokenPurpose, ttl time.Duration, ip string) (string, time.Time, error) {
+	if err := s.repo.DeleteUserActionTokens(ctx, user.Id, purpose); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+
+	if err := s.repo.InsertActionToken(ctx, &domain.ActionToken{
+		Purpose:   purpose,
+		TokenHash: hashRefreshToken(token),
+		UserID:    user.Id,
+		IPAddress: ip,
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// sendActionToken issues a token and delivers the message built around it in the background.
+// Enumeration-safe endpoints use it so a known address costs the caller no more time than an
+// unknown one: the token writes and the notifier run only after the response has been sent.
+func (s *identityAuthService) sendActionToken(user *domain.User, purpose domain.ActionTokenPurpose, ttl time.Duration, ip string, build func(token string, expiresAt time.Time) notify.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		logger := logrus.WithField("scope", "notify").WithField("purpose", purpose).WithField("user_id", user.Id.Hex())
+
+		token, expiresAt, err := s.issueActionToken(ctx, user, purpose, ttl, ip)
+		if err != nil {
+			logger.WithError(err).Error("action token issue failed")
+			return
+		}
+
+		msg := build(token, expiresAt)
+		if err := s.notifier.Send(ctx, msg); err != nil {
+			logger.WithError(err).WithField("template", msg.Template).Error("notification delivery failed")
+		}
+	}()
+}
+
+func newOpaqueToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func actionLink(baseURL, token string) string {
+	if baseURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -3,8 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"net/mail"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/invenlore/core/pkg/config"
 	"github.com/invenlore/identity.service/internal/domain"
 	"github.com/invenlore/identity.service/internal/repository"
 	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
@@ -13,19 +17,40 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+// Limits on ImportUsers, matching what the transport enforces for AddUser.
+const (
+	importUsersMaxBatch           = 500
+	importedEmailMaxLength        = 254
+	importedNameMaxLength         = 100
+	importedPasswordHashMaxLength = 1024
+	importedRolesMax              = 20
+	importedRoleMaxLength         = 100
+)
+
 type identityAdminService struct {
 	Repository repository.IdentityAdminRepository
+	briefs     IdentityUserBriefService
+	throttler  *LoginThrottler
+	accessTTL  time.Duration
 }
 
 type IdentityAdminService interface {
 	AddUser(context.Context, *identity_v1.User) (string, codes.Code, error)
 	GetUser(context.Context, string) (*identity_v1.User, codes.Code, error)
 	DeleteUser(context.Context, string) (codes.Code, error)
+	SetUserStatus(context.Context, string, domain.UserStatusUpdate) (*identity_v1.User, codes.Code, error)
 	ListUsers(ctx context.Context) ([]*identity_v1.User, string, codes.Code, error)
+	ListUserSessions(context.Context, string) ([]*identity_v1.Session, codes.Code, error)
+	RevokeUserSession(context.Context, string, string) (codes.Code, error)
+	RevokeUserSessions(context.Context, string) (int64, codes.Code, error)
+	ListLoginThrottles(context.Context, bool, int64) ([]*identity_v1.LoginThrottle, codes.Code, error)
+	ClearLoginThrottle(context.Context, string, string) (int64, codes.Code, error)
+	ImportUsers(context.Context, []*identity_v1.ImportedUser) (int64, []*identity_v1.ImportUserFailure, codes.Code, error)
+	CountLegacyPasswordHashes(context.Context) (int64, codes.Code, error)
 }
 
-func NewIdentityAdminService(repository repository.IdentityAdminRepository) IdentityAdminService {
-	return &identityAdminService{Repository: repository}
+func NewIdentityAdminService(repository repository.IdentityAdminRepository, briefs IdentityUserBriefService, throttler *LoginThrottler, authCfg *config.AuthConfig) IdentityAdminService {
+	return &identityAdminService{Repository: repository, briefs: briefs, throttler: throttler, accessTTL: authCfg.AccessTokenTTL}
 }
 
 func (s *identityAdminService) AddUser(ctx context.Context, u *identity_v1.User) (string, codes.Code, error) {
@@ -62,14 +87,7 @@ func (s *identityAdminService) GetUser(ctx context.Context, id string) (*identit
 		}
 	}
 
-	return &identity_v1.User{
-		Id:        ptrUser.Id.Hex(),
-		Name:      ptrUser.Name,
-		Email:     ptrUser.Email,
-		Roles:     ptrUser.Roles,
-		CreatedAt: ptrUser.CreatedAt.Unix(),
-		UpdatedAt: ptrUser.UpdatedAt.Unix(),
-	}, codes.OK, nil
+	return userToProto(ptrUser), codes.OK, nil
 }
 
 func (s *identityAdminService) DeleteUser(ctx context.Context, id string) (codes.Code, error) {
@@ -87,9 +105,61 @@ func (s *identityAdminService) DeleteUser(ctx context.Context, id string) (codes
 		return codes.NotFound, fmt.Errorf("user for id (%s) is not found", id)
 	}
 
+	s.briefs.Forget(id)
+
+	if _, err := revokeUserSessions(ctx, s.Repository, objID, "", domain.SessionRevokeReasonUserDeleted, s.accessTTL); err != nil {
+		return codes.Internal, err
+	}
+
 	return codes.OK, nil
 }
 
+func (s *identityAdminService) SetUserStatus(ctx context.Context, id string, status domain.UserStatusUpdate) (*identity_v1.User, codes.Code, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, codes.InvalidArgument, fmt.Errorf("converting string to ObjectID failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+
+	switch status.Status {
+	case domain.UserStatusActive, domain.UserStatusDisabled:
+		status.SuspendedUntil = nil
+	case domain.UserStatusSuspended:
+		if status.SuspendedUntil != nil && !status.SuspendedUntil.After(now) {
+			return nil, codes.InvalidArgument, fmt.Errorf("suspended until must be in the future")
+		}
+	default:
+		return nil, codes.InvalidArgument, fmt.Errorf("unknown user status %q", status.Status)
+	}
+
+	user, err := s.Repository.UpdateUserStatus(ctx, objID, status, now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.NotFound, fmt.Errorf("user for id (%s) is not found", id)
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	revokeReason := ""
+
+	switch status.Status {
+	case domain.UserStatusSuspended:
+		revokeReason = domain.SessionRevokeReasonUserSuspended
+	case domain.UserStatusDisabled:
+		revokeReason = domain.SessionRevokeReasonUserDisabled
+	}
+
+	if revokeReason != "" {
+		if _, err := revokeUserSessions(ctx, s.Repository, objID, "", revokeReason, s.accessTTL); err != nil {
+			return nil, codes.Internal, err
+		}
+	}
+
+	return userToProto(user), codes.OK, nil
+}
+
 func (s *identityAdminService) ListUsers(ctx context.Context) ([]*identity_v1.User, string, codes.Code, error) {
 	users := make([]*identity_v1.User, 0)
 
@@ -99,15 +169,198 @@ func (s *identityAdminService) ListUsers(ctx context.Context) ([]*identity_v1.Us
 	}
 
 	for _, u := range dbUsers {
-		users = append(users, &identity_v1.User{
-			Id:        u.Id.Hex(),
-			Name:      u.Name,
-			Email:     u.Email,
-			Roles:     u.Roles,
-			CreatedAt: u.CreatedAt.Unix(),
-			UpdatedAt: u.UpdatedAt.Unix(),
-		})
+		users = append(users, userToProto(u))
 	}
 
 	return users, "", codes.OK, nil
 }
+
+func (s *identityAdminService) ListUserSessions(ctx context.Context, userID string) ([]*identity_v1.Session, codes.Code, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, codes.InvalidArgument, fmt.Errorf("converting string to ObjectID failed: %v", err)
+	}
+
+	sessions, err := s.Repository.ListActiveUserRefreshSessions(ctx, objID, time.Now().UTC())
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	return sessionsToProto(sessions, ""), codes.OK, nil
+}
+
+func (s *identityAdminService) RevokeUserSession(ctx context.Context, userID, sessionID string) (codes.Code, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return codes.InvalidArgument, fmt.Errorf("converting string to ObjectID failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+
+	if err := s.Repository.RevokeUserRefreshSession(ctx, objID, sessionID, now, domain.SessionRevokeReasonAdmin); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.NotFound, fmt.Errorf("session (%s) for user (%s) is not found", sessionID, userID)
+		}
+
+		return codes.Internal, err
+	}
+
+	if err := denySessions(ctx, s.Repository, []string{sessionID}, domain.SessionRevokeReasonAdmin, now, s.accessTTL); err != nil {
+		return codes.Internal, err
+	}
+
+	return codes.OK, nil
+}
+
+func (s *identityAdminService) RevokeUserSessions(ctx context.Context, userID string) (int64, codes.Code, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, codes.InvalidArgument, fmt.Errorf("converting string to ObjectID failed: %v", err)
+	}
+
+	count, err := revokeUserSessions(ctx, s.Repository, objID, "", domain.SessionRevokeReasonAdmin, s.accessTTL)
+	if err != nil {
+		return 0, codes.Internal, err
+	}
+
+	return count, codes.OK, nil
+}
+
+func (s *identityAdminService) ListLoginThrottles(ctx context.Context, lockedOnly bool, limit int64) ([]*identity_v1.LoginThrottle, codes.Code, error) {
+	throttles, err := s.throttler.List(ctx, lockedOnly, limit)
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	result := make([]*identity_v1.LoginThrottle, 0, len(throttles))
+	for _, throttle := range throttles {
+		item := &identity_v1.LoginThrottle{
+			Kind:          string(throttle.Kind),
+			Value:         throttle.Value,
+			Failures:      throttle.Failures,
+			FirstFailedAt: throttle.FirstFailedAt.Unix(),
+			LastFailedAt:  throttle.LastFailedAt.Unix(),
+		}
+
+		if throttle.LockedUntil != nil {
+			item.LockedUntil = throttle.LockedUntil.Unix()
+		}
+
+		result = append(result, item)
+	}
+
+	return result, codes.OK, nil
+}
+
+func (s *identityAdminService) ClearLoginThrottle(ctx context.Context, email, ip string) (int64, codes.Code, error) {
+	cleared, err := s.throttler.Clear(ctx, email, ip)
+	if err != nil {
+		return 0, codes.Internal, err
+	}
+
+	return cleared, codes.OK, nil
+}
+
+// ImportUsers inserts users carried over from other systems with their original password hashes.
+// Entries are independent: an invalid entry, a rejected hash or a taken email is reported and the
+// rest still go in.
+func (s *identityAdminService) ImportUsers(ctx context.Context, users []*identity_v1.ImportedUser) (int64, []*identity_v1.ImportUserFailure, codes.Code, error) {
+	if len(users) == 0 || len(users) > importUsersMaxBatch {
+		return 0, nil, codes.InvalidArgument, fmt.Errorf("between 1 and %d users are required", importUsersMaxBatch)
+	}
+
+	var imported int64
+	failures := make([]*identity_v1.ImportUserFailure, 0)
+
+	for _, u := range users {
+		if u == nil {
+			failures = append(failures, &identity_v1.ImportUserFailure{Reason: "user is required"})
+			continue
+		}
+
+		email := strings.ToLower(strings.TrimSpace(u.Email))
+
+		if err := validateImportedUser(u); err != nil {
+			failures = append(failures, &identity_v1.ImportUserFailure{Email: email, Reason: err.Error()})
+			continue
+		}
+
+		if _, err := PasswordHashScheme(u.PasswordHash); err != nil {
+			failures = append(failures, &identity_v1.ImportUserFailure{Email: email, Reason: err.Error()})
+			continue
+		}
+
+		roles := u.Roles
+		if len(roles) == 0 {
+			roles = []string{"user"}
+		}
+
+		now := time.Now().UTC()
+		user := &domain.User{
+			Name:         strings.TrimSpace(u.Name),
+			Email:        email,
+			Roles:        roles,
+			Status:       domain.UserStatusActive,
+			PasswordHash: u.PasswordHash,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+
+		if u.EmailVerified {
+			user.EmailVerifiedAt = &now
+		}
+
+		if _, err := s.Repository.InsertUser(ctx, user); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				failures = append(failures, &identity_v1.ImportUserFailure{Email: email, Reason: "email already exists"})
+				continue
+			}
+
+			return imported, failures, codes.Internal, err
+		}
+
+		imported++
+	}
+
+	return imported, failures, codes.OK, nil
+}
+
+// validateImportedUser applies the limits a registered user is held to, since imported entries
+// come from systems that may not have enforced them.
+func validateImportedUser(u *identity_v1.ImportedUser) error {
+	email := strings.TrimSpace(u.Email)
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email || len(email) > importedEmailMaxLength {
+		return fmt.Errorf("email is invalid")
+	}
+
+	if name := strings.TrimSpace(u.Name); name == "" || utf8.RuneCountInString(name) > importedNameMaxLength {
+		return fmt.Errorf("name must be between 1 and %d characters", importedNameMaxLength)
+	}
+
+	if len(u.PasswordHash) > importedPasswordHashMaxLength {
+		return fmt.Errorf("password hash is too long")
+	}
+
+	if len(u.Roles) > importedRolesMax {
+		return fmt.Errorf("at most %d roles are allowed", importedRolesMax)
+	}
+
+	for _, role := range u.Roles {
+		if role == "" || len(role) > importedRoleMaxLength {
+			return fmt.Errorf("roles must be between 1 and %d characters", importedRoleMaxLength)
+		}
+	}
+
+	return nil
+}
+
+// CountLegacyPasswordHashes reports how many users still have a hash that is not argon2id PHC;
+// it reaches zero once every imported and pre-PHC user has logged in at least once.
+func (s *identityAdminService) CountLegacyPasswordHashes(ctx context.Context) (int64, codes.Code, error) {
+	count, err := s.Repository.CountLegacyPasswordHashes(ctx)
+	if err != nil {
+		return 0, codes.Internal, err
+	}
+
+	return count, codes.OK, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/repository"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+)
+
+type fakeAdminRepo struct {
+	repository.IdentityAdminRepository
+
+	inserted []*domain.User
+}
+
+func (r *fakeAdminRepo) InsertUser(_ context.Context, user *domain.User) (primitive.ObjectID, error) {
+	r.inserted = append(r.inserted, user)
+	return primitive.NewObjectID(), nil
+}
+
+func TestImportUsersReportsInvalidEntries(t *testing.T) {
+	repo := &fakeAdminRepo{}
+	svc := &identityAdminService{Repository: repo}
+
+	encoded, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := string(encoded)
+
+	users := []*identity_v1.ImportedUser{
+		{Email: " Good@Example.com ", Name: "Good", PasswordHash: hash},
+		nil,
+		{Email: "not an email", Name: "Bad Email", PasswordHash: hash},
+		{Email: "noname@example.com", Name: "  ", PasswordHash: hash},
+		{Email: "roles@example.com", Name: "Roles", PasswordHash: hash, Roles: []string{""}},
+		{Email: "hash@example.com", Name: "Hash", PasswordHash: "plaintext"},
+	}
+
+	imported, failures, code, err := svc.ImportUsers(context.Background(), users)
+	if err != nil || code != codes.OK {
+		t.Fatalf("ImportUsers: %s, %v", code, err)
+	}
+
+	if imported != 1 || len(repo.inserted) != 1 || repo.inserted[0].Email != "good@example.com" {
+		t.Fatalf("imported %d, inserted %+v", imported, repo.inserted)
+	}
+
+	if len(failures) != len(users)-1 {
+		t.Fatalf("got %d failures, want %d: %v", len(failures), len(users)-1, failures)
+	}
+}
+
+func TestImportUsersCapsTheBatch(t *testing.T) {
+	svc := &identityAdminService{Repository: &fakeAdminRepo{}}
+
+	for _, n := range []int{0, importUsersMaxBatch + 1} {
+		if _, _, code, err := svc.ImportUsers(context.Background(), make([]*identity_v1.ImportedUser, n)); code != codes.InvalidArgument || err == nil {
+			t.Fatalf("%d users: %s, %v", n, code, err)
+		}
+	}
+}
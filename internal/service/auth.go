@@ -3,12 +3,11 @@ package service
 import (
 	"context"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/pem"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,10 +15,13 @@ import (
 	"github.com/google/uuid"
 	"github.com/invenlore/core/pkg/config"
 	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/notify"
 	"github.com/invenlore/identity.service/internal/repository"
+	"github.com/invenlore/identity.service/internal/settings"
 	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"golang.org/x/crypto/argon2"
 	"google.golang.org/grpc/codes"
 )
 
@@ -29,24 +31,126 @@ type IdentityAuthService interface {
 	Refresh(ctx context.Context, req *identity_v1.RefreshRequest, userAgent, ip string) (*identity_v1.RefreshResponse, codes.Code, error)
 	Logout(ctx context.Context, req *identity_v1.LogoutRequest) (codes.Code, error)
 	GetJWKS(ctx context.Context) (*identity_v1.JWKSet, codes.Code, error)
+	ValidateToken(ctx context.Context, token string) (*identity_v1.ValidateTokenResponse, codes.Code, error)
+	GetProfile(ctx context.Context, accessToken string) (*identity_v1.User, codes.Code, error)
+	UpdateProfile(ctx context.Context, accessToken string, profile domain.UserProfileUpdate) (*identity_v1.User, codes.Code, error)
+	ListSessions(ctx context.Context, accessToken string) ([]*identity_v1.Session, codes.Code, error)
+	RevokeSession(ctx context.Context, accessToken, sessionID string) (codes.Code, error)
+	RevokeOtherSessions(ctx context.Context, accessToken, refreshToken string) (int64, codes.Code, error)
+	RequestPasswordReset(ctx context.Context, email, ip string) (codes.Code, error)
+	ConfirmPasswordReset(ctx context.Context, token, newPassword string) (codes.Code, error)
+	VerifyEmail(ctx context.Context, token string) (codes.Code, error)
+	ChangePassword(ctx context.Context, accessToken, currentPassword, newPassword string) (int64, codes.Code, error)
+	ResendVerification(ctx context.Context, email, ip string) (codes.Code, error)
+	BeginTOTPEnrollment(ctx context.Context, accessToken string) (*identity_v1.BeginTOTPEnrollmentResponse, codes.Code, error)
+	ConfirmTOTPEnrollment(ctx context.Context, accessToken, code string) ([]string, codes.Code, error)
+	VerifyMFA(ctx context.Context, challengeToken, code, recoveryCode, userAgent, ip string) (*identity_v1.VerifyMFAResponse, codes.Code, error)
+	RegenerateRecoveryCodes(ctx context.Context, accessToken, password string) ([]string, codes.Code, error)
+	GetRecoveryCodesStatus(ctx context.Context, accessToken string) (int, int, codes.Code, error)
+	BeginWebAuthnRegistration(ctx context.Context, accessToken string) (string, codes.Code, error)
+	FinishWebAuthnRegistration(ctx context.Context, accessToken, name string, clientDataJSON, attestationObject []byte, transports []string) (string, codes.Code, error)
+	BeginWebAuthnLogin(ctx context.Context, ip string) (string, codes.Code, error)
+	FinishWebAuthnLogin(ctx context.Context, credentialID string, clientDataJSON, authenticatorData, signature, userHandle []byte, userAgent, ip string) (*identity_v1.LoginResponse, codes.Code, error)
 	EnsureActiveKey(ctx context.Context) error
 }
 
 type identityAuthService struct {
-	repo       repository.IdentityAuthRepository
-	accessTTL  time.Duration
-	refreshTTL time.Duration
-	issuer     string
-	audience   string
+	repo               repository.IdentityAuthRepository
+	briefs             IdentityUserBriefService
+	keys               *PublicKeyCache
+	keyProvider        KeyProvider
+	events             SecurityEventEmitter
+	notifier           notify.Notifier
+	throttler          *LoginThrottler
+	passwordPolicy     *PasswordPolicy
+	passwords          *passwordHasher
+	accessTTL          time.Duration
+	refreshTTL         time.Duration
+	issuer             string
+	audience           string
+	reuseRevokesAll    bool
+	refreshGraceWindow time.Duration
+	passwordResetTTL   time.Duration
+	passwordResetURL   string
+
+	emailVerificationPolicy string
+	emailVerificationTTL    time.Duration
+	emailVerificationURL    string
+
+	mfaBox          *secretBox
+	mfaIssuer       string
+	mfaChallengeTTL time.Duration
+
+	webauthnRPID         string
+	webauthnRPName       string
+	webauthnOrigins      []string
+	webauthnChallengeTTL time.Duration
+	webauthnLoginLimiter *ipRateLimiter
 }
 
-func NewIdentityAuthService(repo repository.IdentityAuthRepository, authCfg *config.AuthConfig) IdentityAuthService {
+// errInvalidCredentials is the single answer Login gives for unknown emails and wrong passwords.
+var errInvalidCredentials = errors.New("invalid credentials")
+
+type accessTokenClaims struct {
+	jwt.RegisteredClaims
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles"`
+	// EmailVerified is only present under the "claim" email verification policy.
+	EmailVerified *bool    `json:"email_verified,omitempty"`
+	AMR           []string `json:"amr,omitempty"`
+}
+
+func NewIdentityAuthService(repo repository.IdentityAuthRepository, briefs IdentityUserBriefService, keys *PublicKeyCache, keyProvider KeyProvider, events SecurityEventEmitter, notifier notify.Notifier, throttler *LoginThrottler, passwordPolicy *PasswordPolicy, hashLimiter *PasswordHashLimiter, authCfg *config.AuthConfig, authSettings *settings.AuthSettings) IdentityAuthService {
+	var mfaBox *secretBox
+	if len(authSettings.MFAEncryptionKey) > 0 {
+		box, err := newSecretBox(authSettings.MFAEncryptionKey)
+		if err != nil {
+			logrus.WithError(err).Error("mfa encryption key rejected, mfa enrollment is disabled")
+		}
+
+		mfaBox = box
+	}
+
+	passwords := newPasswordHasher(authSettings, hashLimiter)
+
+	webauthnRPID := strings.TrimSpace(authSettings.WebAuthnRPID)
+	webauthnOrigins := authSettings.WebAuthnOrigins
+	if len(webauthnOrigins) == 0 && webauthnRPID != "" {
+		webauthnOrigins = []string{"https://" + webauthnRPID}
+	}
+
 	return &identityAuthService{
-		repo:       repo,
-		accessTTL:  authCfg.AccessTokenTTL,
-		refreshTTL: authCfg.RefreshTokenTTL,
-		issuer:     strings.TrimSpace(authCfg.JWTIssuer),
-		audience:   strings.TrimSpace(authCfg.JWTAudience),
+		repo:               repo,
+		briefs:             briefs,
+		keys:               keys,
+		keyProvider:        keyProvider,
+		events:             events,
+		notifier:           notifier,
+		throttler:          throttler,
+		passwordPolicy:     passwordPolicy,
+		passwords:          passwords,
+		accessTTL:          authCfg.AccessTokenTTL,
+		refreshTTL:         authCfg.RefreshTokenTTL,
+		issuer:             strings.TrimSpace(authCfg.JWTIssuer),
+		audience:           strings.TrimSpace(authCfg.JWTAudience),
+		reuseRevokesAll:    authSettings.RefreshReuseRevokeAllSessions,
+		refreshGraceWindow: authSettings.RefreshGraceWindow,
+		passwordResetTTL:   authSettings.PasswordResetTTL,
+		passwordResetURL:   strings.TrimSpace(authSettings.PasswordResetURL),
+
+		emailVerificationPolicy: authSettings.EmailVerificationPolicy,
+		emailVerificationTTL:    authSettings.EmailVerificationTTL,
+		emailVerificationURL:    strings.TrimSpace(authSettings.EmailVerificationURL),
+
+		mfaBox:          mfaBox,
+		mfaIssuer:       strings.TrimSpace(authSettings.MFAIssuer),
+		mfaChallengeTTL: authSettings.MFAChallengeTTL,
+
+		webauthnRPID:         webauthnRPID,
+		webauthnRPName:       strings.TrimSpace(authSettings.WebAuthnRPName),
+		webauthnOrigins:      webauthnOrigins,
+		webauthnChallengeTTL: authSettings.WebAuthnChallengeTTL,
+		webauthnLoginLimiter: newIPRateLimiter(authSettings.WebAuthnLoginRateLimit, authSettings.WebAuthnLoginRateWindow),
 	}
 }
 
@@ -55,13 +159,23 @@ func (s *identityAuthService) Register(ctx context.Context, req *identity_v1.Reg
 		return nil, codes.InvalidArgument, fmt.Errorf("email and password are required")
 	}
 
+	if err := s.passwordPolicy.Check("password", req.Password, req.Email, req.Name); err != nil {
+		return nil, codes.InvalidArgument, err
+	}
+
+	passwordHash, err := s.passwords.hash(ctx, req.Password)
+	if err != nil {
+		return nil, passwordHashCode(err), err
+	}
+
 	now := time.Now().UTC()
 
 	user := &domain.User{
 		Name:         strings.TrimSpace(req.Name),
 		Email:        strings.ToLower(strings.TrimSpace(req.Email)),
 		Roles:        []string{"user"},
-		PasswordHash: hashPassword(req.Password),
+		Status:       domain.UserStatusActive,
+		PasswordHash: passwordHash,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
@@ -75,16 +189,13 @@ func (s *identityAuthService) Register(ctx context.Context, req *identity_v1.Reg
 		return nil, codes.Internal, err
 	}
 
+	user.Id = id
+
+	s.sendEmailVerification(user, "")
+
 	return &identity_v1.RegisterResponse{
-		Id: id.Hex(),
-		User: &identity_v1.User{
-			Id:        id.Hex(),
-			Name:      user.Name,
-			Email:     user.Email,
-			Roles:     user.Roles,
-			CreatedAt: user.CreatedAt.Unix(),
-			UpdatedAt: user.UpdatedAt.Unix(),
-		},
+		Id:   id.Hex(),
+		User: userToProto(user),
 	}, codes.OK, nil
 }
 
@@ -93,44 +204,160 @@ func (s *identityAuthService) Login(ctx context.Context, req *identity_v1.LoginR
 		return nil, codes.InvalidArgument, fmt.Errorf("email and password are required")
 	}
 
+	if code, err := s.checkLoginThrottle(ctx, req.Email, ip); err != nil {
+		return nil, code, err
+	}
+
 	user, err := s.repo.FindUserByEmail(ctx, strings.ToLower(strings.TrimSpace(req.Email)))
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, codes.NotFound, fmt.Errorf("user not found")
+			// Spend the same argon2 work as a real check so unknown emails cannot be told apart by latency.
+			if err := s.passwords.verifyDummy(ctx, req.Password); err != nil {
+				return nil, passwordHashCode(err), err
+			}
+
+			s.loginFailed(ctx, req.Email, ip)
+
+			return nil, codes.Unauthenticated, errInvalidCredentials
 		}
 
 		return nil, codes.Internal, err
 	}
 
-	if !verifyPassword(req.Password, user.PasswordHash) {
-		return nil, codes.Unauthenticated, fmt.Errorf("invalid credentials")
+	ok, err := s.passwords.verify(ctx, req.Password, user.PasswordHash)
+	if err != nil {
+		return nil, passwordHashCode(err), err
+	}
+
+	if !ok {
+		s.loginFailed(ctx, req.Email, ip)
+		return nil, codes.Unauthenticated, errInvalidCredentials
+	}
+
+	s.upgradePasswordHash(ctx, user, req.Password)
+
+	if err := checkAccountStatus(user); err != nil {
+		return nil, codes.PermissionDenied, err
+	}
+
+	// A recovery code stands in for whatever else would block the login: the MFA challenge
+	// and the email verification requirement.
+	if strings.TrimSpace(req.RecoveryCode) != "" {
+		if code, err := s.useRecoveryCode(ctx, user, req.RecoveryCode, userAgent, ip); err != nil {
+			if code == codes.Unauthenticated {
+				s.loginFailed(ctx, req.Email, ip)
+			}
+
+			return nil, code, err
+		}
+
+		s.loginSucceeded(ctx, req.Email)
+
+		resp, err := s.completeLogin(ctx, user, userAgent, ip, recoveryAMR())
+		if err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return resp, codes.OK, nil
+	}
+
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, codes.FailedPrecondition, err
 	}
 
-	accessToken, expiresIn, err := s.issueAccessToken(ctx, user)
+	// The email's failures are only forgiven once every factor has passed, in completeMFALogin when
+	// a challenge is needed, so a known password does not reset the budget for guessing TOTP codes.
+	if user.TOTPEnabled() {
+		resp, err := s.startMFAChallenge(ctx, user, ip)
+		if err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return resp, codes.OK, nil
+	}
+
+	s.loginSucceeded(ctx, req.Email)
+
+	resp, err := s.completeLogin(ctx, user, userAgent, ip, []string{domain.AuthMethodPassword})
 	if err != nil {
 		return nil, codes.Internal, err
 	}
 
-	refreshToken, sessionID, err := s.issueRefreshSession(ctx, user, userAgent, ip)
+	return resp, codes.OK, nil
+}
+
+func (s *identityAuthService) checkLoginThrottle(ctx context.Context, email, ip string) (codes.Code, error) {
+	if err := s.throttler.Check(ctx, email, ip); err != nil {
+		var throttled *LoginThrottledError
+		if errors.As(err, &throttled) {
+			return codes.ResourceExhausted, err
+		}
+
+		return codes.Internal, err
+	}
+
+	return codes.OK, nil
+}
+
+// Throttle bookkeeping must not turn a login answer into an internal error, so failures are only logged.
+func (s *identityAuthService) loginFailed(ctx context.Context, email, ip string) {
+	if err := s.throttler.RecordFailure(ctx, email, ip); err != nil {
+		logrus.WithField("scope", "login-throttle").WithError(err).Error("recording failed login failed")
+	}
+}
+
+// upgradePasswordHash rewrites a verified password with the target parameters when the stored hash
+// is legacy or weaker. It is best effort: the login goes ahead with the old hash on any failure.
+func (s *identityAuthService) upgradePasswordHash(ctx context.Context, user *domain.User, password string) {
+	if !s.passwords.needsRehash(user.PasswordHash) {
+		return
+	}
+
+	scheme, _ := PasswordHashScheme(user.PasswordHash)
+	upgraded, err := s.passwords.hash(ctx, password)
 	if err != nil {
-		return nil, codes.Internal, err
+		// Under load the upgrade simply waits for a later login.
+		return
+	}
+
+	// Conditional on the old hash so a password changed in the meantime is not overwritten.
+	if err := s.repo.ReplaceUserPasswordHash(ctx, user.Id, user.PasswordHash, upgraded); err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.WithField("scope", "password-hash").WithError(err).Error("password hash upgrade failed")
+		}
+
+		return
 	}
 
-	_ = sessionID
+	logrus.WithField("scope", "password-hash").WithField("user_id", user.Id.Hex()).WithField("from", scheme).Debug("password hash upgraded")
+
+	user.PasswordHash = upgraded
+}
+
+func (s *identityAuthService) loginSucceeded(ctx context.Context, email string) {
+	if err := s.throttler.RecordSuccess(ctx, email); err != nil {
+		logrus.WithField("scope", "login-throttle").WithError(err).Error("clearing login failures failed")
+	}
+}
+
+// completeLogin opens a session once every required factor has been checked; amr records which.
+func (s *identityAuthService) completeLogin(ctx context.Context, user *domain.User, userAgent, ip string, amr []string) (*identity_v1.LoginResponse, error) {
+	refreshToken, sessionID, err := s.issueRefreshSession(ctx, user, userAgent, ip, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresIn, err := s.issueAccessToken(ctx, user, sessionID, amr)
+	if err != nil {
+		return nil, err
+	}
 
 	return &identity_v1.LoginResponse{
 		AccessToken:      accessToken,
 		RefreshToken:     refreshToken,
 		ExpiresInSeconds: expiresIn,
-		User: &identity_v1.User{
-			Id:        user.Id.Hex(),
-			Name:      user.Name,
-			Email:     user.Email,
-			Roles:     user.Roles,
-			CreatedAt: user.CreatedAt.Unix(),
-			UpdatedAt: user.UpdatedAt.Unix(),
-		},
-	}, codes.OK, nil
+		User:             userToProto(user),
+	}, nil
 }
 
 func (s *identityAuthService) Refresh(ctx context.Context, req *identity_v1.RefreshRequest, userAgent, ip string) (*identity_v1.RefreshResponse, codes.Code, error) {
@@ -138,11 +365,13 @@ func (s *identityAuthService) Refresh(ctx context.Context, req *identity_v1.Refr
 		return nil, codes.InvalidArgument, fmt.Errorf("refresh token is required")
 	}
 
-	sessionID, tokenHash, err := splitRefreshToken(req.RefreshToken)
+	sessionID, secret, err := parseRefreshToken(req.RefreshToken)
 	if err != nil {
 		return nil, codes.InvalidArgument, err
 	}
 
+	tokenHash := hashRefreshToken(secret)
+
 	session, err := s.repo.FindRefreshSession(ctx, sessionID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -156,15 +385,27 @@ func (s *identityAuthService) Refresh(ctx context.Context, req *identity_v1.Refr
 		return nil, codes.Unauthenticated, fmt.Errorf("refresh token expired")
 	}
 	if session.RefreshTokenHash != tokenHash {
-		return nil, codes.Unauthenticated, fmt.Errorf("refresh token invalid")
+		return s.refreshWithRotatedToken(ctx, session, secret, tokenHash, userAgent, ip)
 	}
 
 	user, err := s.repo.FindUserByID(ctx, session.UserID)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			if err := s.repo.RevokeRefreshSession(ctx, session.SessionID, time.Now().UTC(), domain.SessionRevokeReasonUserMissing); err != nil {
+				return nil, codes.Internal, err
+			}
+
+			return nil, codes.Unauthenticated, fmt.Errorf("refresh token invalid")
+		}
+
 		return nil, codes.Internal, err
 	}
 
-	accessToken, expiresIn, err := s.issueAccessToken(ctx, user)
+	if err := checkAccountStatus(user); err != nil {
+		return nil, codes.PermissionDenied, err
+	}
+
+	accessToken, expiresIn, err := s.issueAccessToken(ctx, user, session.SessionID, session.AMR)
 	if err != nil {
 		return nil, codes.Internal, err
 	}
@@ -174,18 +415,69 @@ func (s *identityAuthService) Refresh(ctx context.Context, req *identity_v1.Refr
 		return nil, codes.Internal, err
 	}
 
+	resp := &identity_v1.RefreshResponse{
+		AccessToken:      accessToken,
+		RefreshToken:     newRefreshToken,
+		ExpiresInSeconds: expiresIn,
+	}
+
 	now := time.Now().UTC()
 	newExpires := now.Add(s.refreshTTL)
 
-	if err := s.repo.RotateRefreshSession(ctx, session.SessionID, newHash, newExpires, now); err != nil {
+	sealed, err := sealRefreshGrace(secret, resp)
+	if err != nil {
 		return nil, codes.Internal, err
 	}
 
-	return &identity_v1.RefreshResponse{
-		AccessToken:      accessToken,
-		RefreshToken:     newRefreshToken,
-		ExpiresInSeconds: expiresIn,
-	}, codes.OK, nil
+	grace := &domain.RefreshGrace{
+		PreviousTokenHash: tokenHash,
+		SealedResponse:    sealed,
+		Until:             now.Add(s.refreshGraceWindow),
+	}
+
+	if err := s.repo.RotateRefreshSession(ctx, session.SessionID, tokenHash, newHash, newExpires, now, grace); err != nil {
+		if err != mongo.ErrNoDocuments {
+			return nil, codes.Internal, err
+		}
+
+		// Lost the compare-and-swap to a concurrent refresh with the same token.
+		session, err = s.repo.FindRefreshSession(ctx, sessionID)
+		if err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return s.refreshWithRotatedToken(ctx, session, secret, tokenHash, userAgent, ip)
+	}
+
+	return resp, codes.OK, nil
+}
+
+// refreshWithRotatedToken handles a token that is no longer the session's current one: inside
+// the grace window the immediately previous token gets the already issued pair again, any other
+// previously rotated token is treated as reuse.
+func (s *identityAuthService) refreshWithRotatedToken(ctx context.Context, session *domain.RefreshSession, secret, tokenHash, userAgent, ip string) (*identity_v1.RefreshResponse, codes.Code, error) {
+	if session.RevokedAt != nil {
+		return nil, codes.Unauthenticated, fmt.Errorf("refresh token expired")
+	}
+
+	if grace := session.Grace; grace != nil && grace.PreviousTokenHash == tokenHash && time.Now().Before(grace.Until) {
+		resp, err := openRefreshGrace(secret, grace.SealedResponse)
+		if err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return resp, codes.OK, nil
+	}
+
+	if slices.Contains(session.PreviousTokenHashes, tokenHash) {
+		if err := s.handleRefreshTokenReuse(ctx, session, userAgent, ip); err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return nil, codes.Unauthenticated, fmt.Errorf("refresh token reuse detected")
+	}
+
+	return nil, codes.Unauthenticated, fmt.Errorf("refresh token invalid")
 }
 
 func (s *identityAuthService) Logout(ctx context.Context, req *identity_v1.LogoutRequest) (codes.Code, error) {
@@ -198,7 +490,9 @@ func (s *identityAuthService) Logout(ctx context.Context, req *identity_v1.Logou
 		return codes.InvalidArgument, err
 	}
 
-	if err := s.repo.RevokeRefreshSession(ctx, sessionID, time.Now().UTC()); err != nil {
+	now := time.Now().UTC()
+
+	if err := s.repo.RevokeRefreshSession(ctx, sessionID, now, domain.SessionRevokeReasonLogout); err != nil {
 		if err == mongo.ErrNoDocuments {
 			return codes.NotFound, fmt.Errorf("session not found")
 		}
@@ -206,18 +500,22 @@ func (s *identityAuthService) Logout(ctx context.Context, req *identity_v1.Logou
 		return codes.Internal, err
 	}
 
+	if err := denySessions(ctx, s.repo, []string{sessionID}, domain.SessionRevokeReasonLogout, now, s.accessTTL); err != nil {
+		return codes.Internal, err
+	}
+
 	return codes.OK, nil
 }
 
 func (s *identityAuthService) GetJWKS(ctx context.Context) (*identity_v1.JWKSet, codes.Code, error) {
-	keys, err := s.repo.ListActivePublicKeys(ctx)
+	keys, err := s.keyProvider.PublicKeys(ctx)
 	if err != nil {
 		return nil, codes.Internal, err
 	}
 
 	result := &identity_v1.JWKSet{Keys: make([]*identity_v1.JWK, 0, len(keys))}
 	for _, key := range keys {
-		jwk, err := buildJWKFromPEM(key)
+		jwk, err := buildJWK(key)
 
 		if err != nil {
 			return nil, codes.Internal, err
@@ -229,27 +527,61 @@ func (s *identityAuthService) GetJWKS(ctx context.Context) (*identity_v1.JWKSet,
 	return result, codes.OK, nil
 }
 
-func (s *identityAuthService) EnsureActiveKey(ctx context.Context) error {
-	return EnsureActiveKey(ctx, s.repo)
+func (s *identityAuthService) ValidateToken(ctx context.Context, token string) (*identity_v1.ValidateTokenResponse, codes.Code, error) {
+	if strings.TrimSpace(token) == "" {
+		return nil, codes.InvalidArgument, fmt.Errorf("token is required")
+	}
+
+	claims, err := s.parseAccessToken(ctx, strings.TrimSpace(token))
+	if err != nil {
+		return nil, codes.Unauthenticated, fmt.Errorf("token invalid: %v", err)
+	}
+
+	return &identity_v1.ValidateTokenResponse{
+		Subject:   claims.Subject,
+		Roles:     claims.Roles,
+		IssuedAt:  claims.IssuedAt.Unix(),
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		Amr:       claims.AMR,
+	}, codes.OK, nil
 }
 
-func (s *identityAuthService) issueAccessToken(ctx context.Context, user *domain.User) (string, int64, error) {
-	key, err := s.repo.FindActiveAuthKey(ctx)
+func (s *identityAuthService) GetProfile(ctx context.Context, accessToken string) (*identity_v1.User, codes.Code, error) {
+	user, _, code, err := s.authenticate(ctx, accessToken)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			if err := EnsureActiveKey(ctx, s.repo); err != nil {
-				return "", 0, err
-			}
+		return nil, code, err
+	}
 
-			key, err = s.repo.FindActiveAuthKey(ctx)
-		}
+	return userToProto(user), codes.OK, nil
+}
 
-		if err != nil {
-			return "", 0, err
+func (s *identityAuthService) UpdateProfile(ctx context.Context, accessToken string, profile domain.UserProfileUpdate) (*identity_v1.User, codes.Code, error) {
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, code, err
+	}
+
+	updated, err := s.repo.UpdateUserProfile(ctx, user.Id, profile, time.Now().UTC())
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("user not found")
 		}
+
+		return nil, codes.Internal, err
 	}
 
-	privateKey, err := parseRSAPrivateKey(key.PrivateKeyPEM)
+	s.briefs.Forget(updated.Id.Hex())
+
+	return userToProto(updated), codes.OK, nil
+}
+
+func (s *identityAuthService) EnsureActiveKey(ctx context.Context) error {
+	_, err := s.keyProvider.Signer(ctx)
+	return err
+}
+
+func (s *identityAuthService) issueAccessToken(ctx context.Context, user *domain.User, sessionID string, amr []string) (string, int64, error) {
+	signer, err := s.keyProvider.Signer(ctx)
 	if err != nil {
 		return "", 0, err
 	}
@@ -261,22 +593,29 @@ func (s *identityAuthService) issueAccessToken(ctx context.Context, user *domain
 		Audience:  jwt.ClaimStrings{s.audience},
 		IssuedAt:  jwt.NewNumericDate(now),
 		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		ID:        uuid.NewString(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+	mapClaims := jwt.MapClaims{
 		"sub":   claims.Subject,
 		"iss":   claims.Issuer,
 		"aud":   claims.Audience,
 		"iat":   claims.IssuedAt.Unix(),
 		"exp":   claims.ExpiresAt.Unix(),
+		"jti":   claims.ID,
+		"sid":   sessionID,
 		"roles": user.Roles,
-	})
+	}
 
-	if key.Kid != "" {
-		token.Header["kid"] = key.Kid
+	if len(amr) > 0 {
+		mapClaims["amr"] = amr
 	}
 
-	signed, err := token.SignedString(privateKey)
+	if s.emailVerificationPolicy == settings.EmailVerificationPolicyClaim {
+		mapClaims["email_verified"] = user.EmailVerifiedAt != nil
+	}
+
+	signed, err := signAccessToken(ctx, signer, mapClaims)
 	if err != nil {
 		return "", 0, err
 	}
@@ -284,13 +623,131 @@ func (s *identityAuthService) issueAccessToken(ctx context.Context, user *domain
 	return signed, int64(s.accessTTL.Seconds()), nil
 }
 
-func (s *identityAuthService) issueRefreshSession(ctx context.Context, user *domain.User, userAgent, ip string) (string, string, error) {
-	refreshToken, sessionID, err := buildRefreshToken("")
+func (s *identityAuthService) parseAccessToken(ctx context.Context, token string) (*accessTokenClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(accessTokenSigningAlgs),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+
+	if s.audience != "" {
+		opts = append(opts, jwt.WithAudience(s.audience))
+	}
+
+	claims := &accessTokenClaims{}
+
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key id")
+		}
+
+		key, err := s.keys.Lookup(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if key.alg != t.Method.Alg() {
+			return nil, fmt.Errorf("token algorithm %q does not match key algorithm %q", t.Method.Alg(), key.alg)
+		}
+
+		return key.key, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" || claims.IssuedAt == nil {
+		return nil, fmt.Errorf("token claims incomplete")
+	}
+
+	revoked, err := s.repo.IsTokenRevoked(ctx, claims.ID, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, fmt.Errorf("token revoked")
+	}
+
+	return claims, nil
+}
+
+// authenticate resolves the caller from a bearer access token signed by our own keys.
+func (s *identityAuthService) authenticate(ctx context.Context, accessToken string) (*domain.User, *accessTokenClaims, codes.Code, error) {
+	if strings.TrimSpace(accessToken) == "" {
+		return nil, nil, codes.Unauthenticated, fmt.Errorf("access token is required")
+	}
+
+	claims, err := s.parseAccessToken(ctx, strings.TrimSpace(accessToken))
 	if err != nil {
-		return "", "", err
+		return nil, nil, codes.Unauthenticated, fmt.Errorf("access token invalid: %v", err)
+	}
+
+	userID, err := primitive.ObjectIDFromHex(claims.Subject)
+	if err != nil {
+		return nil, nil, codes.Unauthenticated, fmt.Errorf("access token subject invalid")
 	}
 
-	_, hash, err := splitRefreshToken(refreshToken)
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, codes.Unauthenticated, fmt.Errorf("user not found")
+		}
+
+		return nil, nil, codes.Internal, err
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		return nil, nil, codes.PermissionDenied, err
+	}
+
+	return user, claims, codes.OK, nil
+}
+
+// handleRefreshTokenReuse treats presentation of an already rotated refresh token as theft
+// (OAuth 2.0 Security BCP, section 4.14): the whole session family is revoked.
+func (s *identityAuthService) handleRefreshTokenReuse(ctx context.Context, session *domain.RefreshSession, userAgent, ip string) error {
+	now := time.Now().UTC()
+	revokedSessions := int64(1)
+
+	if err := s.repo.RevokeRefreshSession(ctx, session.SessionID, now, domain.SessionRevokeReasonRefreshTokenReuse); err != nil {
+		return err
+	}
+
+	if err := denySessions(ctx, s.repo, []string{session.SessionID}, domain.SessionRevokeReasonRefreshTokenReuse, now, s.accessTTL); err != nil {
+		return err
+	}
+
+	if s.reuseRevokesAll {
+		count, err := revokeUserSessions(ctx, s.repo, session.UserID, session.SessionID, domain.SessionRevokeReasonRefreshTokenReuse, s.accessTTL)
+		if err != nil {
+			return err
+		}
+
+		revokedSessions += count
+	}
+
+	s.events.Emit(ctx, SecurityEvent{
+		Type:      SecurityEventRefreshTokenReuse,
+		UserID:    session.UserID.Hex(),
+		SessionID: session.SessionID,
+		UserAgent: userAgent,
+		IPAddress: ip,
+		Details: map[string]any{
+			"generation":       session.Generation,
+			"revoked_sessions": revokedSessions,
+		},
+	})
+
+	return nil
+}
+
+func (s *identityAuthService) issueRefreshSession(ctx context.Context, user *domain.User, userAgent, ip string, amr []string) (string, string, error) {
+	sessionID := uuid.NewString()
+
+	refreshToken, hash, err := buildRefreshToken(sessionID)
 	if err != nil {
 		return "", "", err
 	}
@@ -304,6 +761,8 @@ func (s *identityAuthService) issueRefreshSession(ctx context.Context, user *dom
 		IPAddress:        ip,
 		CreatedAt:        now,
 		ExpiresAt:        now.Add(s.refreshTTL),
+		AMR:              amr,
+		Recovery:         slices.Contains(amr, domain.AuthMethodRecovery),
 	}
 
 	if err := s.repo.InsertRefreshSession(ctx, session); err != nil {
@@ -313,29 +772,55 @@ func (s *identityAuthService) issueRefreshSession(ctx context.Context, user *dom
 	return refreshToken, sessionID, nil
 }
 
-func buildRefreshToken(sessionID string) (string, string, error) {
-	if sessionID == "" {
-		sessionID = uuid.NewString()
+func userToProto(user *domain.User) *identity_v1.User {
+	result := &identity_v1.User{
+		Id:            user.Id.Hex(),
+		Name:          user.Name,
+		Email:         user.Email,
+		Roles:         user.Roles,
+		Status:        string(user.EffectiveStatus(time.Now())),
+		StatusReason:  user.StatusReason,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		CreatedAt:     user.CreatedAt.Unix(),
+		UpdatedAt:     user.UpdatedAt.Unix(),
 	}
 
+	if user.SuspendedUntil != nil && result.Status == string(domain.UserStatusSuspended) {
+		result.SuspendedUntil = user.SuspendedUntil.Unix()
+	}
+
+	return result
+}
+
+// buildRefreshToken returns "<sessionID>.<secret>" and the hash of the secret, which is what a
+// session stores and what splitRefreshToken computes for a presented token.
+func buildRefreshToken(sessionID string) (string, string, error) {
 	secret := make([]byte, 32)
 	if _, err := rand.Read(secret); err != nil {
 		return "", "", err
 	}
 
 	token := base64.RawURLEncoding.EncodeToString(secret)
-	return fmt.Sprintf("%s.%s", sessionID, token), sessionID, nil
+	return fmt.Sprintf("%s.%s", sessionID, token), hashRefreshToken(token), nil
 }
 
 func splitRefreshToken(token string) (string, string, error) {
+	sessionID, secret, err := parseRefreshToken(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionID, hashRefreshToken(secret), nil
+}
+
+func parseRefreshToken(token string) (string, string, error) {
 	parts := strings.Split(token, ".")
 
 	if len(parts) != 2 {
 		return "", "", fmt.Errorf("refresh token format invalid")
 	}
 
-	hash := hashRefreshToken(parts[1])
-	return parts[0], hash, nil
+	return parts[0], parts[1], nil
 }
 
 func hashRefreshToken(token string) string {
@@ -347,34 +832,6 @@ func hashRefreshToken(token string) string {
 	return base64.RawURLEncoding.EncodeToString(checksum[:])
 }
 
-func hashPassword(password string) string {
-	salt := make([]byte, 16)
-	_, _ = rand.Read(salt)
-	key := argon2.IDKey([]byte(password), salt, 3, 64*1024, 2, 32)
-
-	return fmt.Sprintf("%s:%s", base64.RawURLEncoding.EncodeToString(salt), base64.RawURLEncoding.EncodeToString(key))
-}
-
-func verifyPassword(password, encoded string) bool {
-	parts := strings.Split(encoded, ":")
-	if len(parts) != 2 {
-		return false
-	}
-
-	salt, err := base64.RawURLEncoding.DecodeString(parts[0])
-	if err != nil {
-		return false
-	}
-
-	stored, err := base64.RawURLEncoding.DecodeString(parts[1])
-	if err != nil {
-		return false
-	}
-
-	key := argon2.IDKey([]byte(password), salt, 3, 64*1024, 2, 32)
-	return subtleCompare(key, stored)
-}
-
 func subtleCompare(a, b []byte) bool {
 	if len(a) != len(b) {
 		return false
@@ -388,61 +845,7 @@ func subtleCompare(a, b []byte) bool {
 	return result == 0
 }
 
-func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode([]byte(pemKey))
-	if block == nil {
-		return nil, fmt.Errorf("invalid private key")
-	}
-
-	return x509.ParsePKCS1PrivateKey(block.Bytes)
-}
-
-func buildJWKFromPEM(key *domain.AuthKey) (*identity_v1.JWK, error) {
-	block, _ := pem.Decode([]byte(key.PublicKeyPEM))
-	if block == nil {
-		return nil, fmt.Errorf("invalid public key")
-	}
-
-	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-
-	rsaKey, ok := parsedKey.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("unsupported public key type")
-	}
-
-	nBytes := rsaKey.N.Bytes()
-	eBytes := make([]byte, 4)
-	e := rsaKey.E
-
-	for i := len(eBytes) - 1; i >= 0; i-- {
-		eBytes[i] = byte(e & 0xff)
-		e >>= 8
-	}
-
-	eBytes = bytesTrimLeftZero(eBytes)
-
-	return &identity_v1.JWK{
-		Kid: key.Kid,
-		Kty: "RSA",
-		Use: "sig",
-		Alg: key.Alg,
-		N:   base64.RawURLEncoding.EncodeToString(nBytes),
-		E:   base64.RawURLEncoding.EncodeToString(eBytes),
-	}, nil
-}
-
-func bytesTrimLeftZero(in []byte) []byte {
-	for len(in) > 1 && in[0] == 0 {
-		in = in[1:]
-	}
-
-	return in
-}
-
-func EnsureActiveKey(ctx context.Context, repo repository.IdentityAuthRepository) error {
+func EnsureActiveKey(ctx context.Context, repo repository.IdentityAuthRepository, vault *AuthKeyVault, alg string) error {
 	_, err := repo.FindActiveAuthKey(ctx)
 	if err == nil {
 		return nil
@@ -452,29 +855,14 @@ func EnsureActiveKey(ctx context.Context, repo repository.IdentityAuthRepository
 		return err
 	}
 
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	key, err := buildAuthKey(alg)
 	if err != nil {
 		return err
 	}
 
-	privPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-
-	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
-	if err != nil {
+	if err := vault.Seal(key); err != nil {
 		return err
 	}
 
-	pubPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
-
-	key := &domain.AuthKey{
-		Kid:           uuid.NewString(),
-		Alg:           "RS256",
-		Use:           "sig",
-		PrivateKeyPEM: string(privPem),
-		PublicKeyPEM:  string(pubPem),
-		Status:        domain.AuthKeyStatusActive,
-		CreatedAt:     time.Now().UTC(),
-	}
-
 	return repo.InsertAuthKey(ctx, key)
 }
@@ -2,13 +2,8 @@ package service
 
 import (
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/invenlore/core/pkg/migrator"
 	"github.com/invenlore/identity.service/internal/domain"
 	"github.com/invenlore/identity.service/internal/repository"
@@ -18,9 +13,12 @@ import (
 
 type AuthKeyRotator struct {
 	repo             repository.IdentityAuthRepository
+	keys             *PublicKeyCache
+	vault            *AuthKeyVault
 	locker           *migrator.Locker
 	rotationInterval time.Duration
 	retireAfter      time.Duration
+	alg              string
 	logger           *logrus.Entry
 }
 
@@ -29,9 +27,11 @@ type AuthKeyRotatorConfig struct {
 	LeaseFor         time.Duration
 	RotationInterval time.Duration
 	RetireAfter      time.Duration
+	// Alg applies to keys created from now on; existing keys keep theirs until they are rotated out.
+	Alg string
 }
 
-func NewAuthKeyRotator(db *mongo.Database, repo repository.IdentityAuthRepository, owner string, cfg AuthKeyRotatorConfig, logger *logrus.Entry) *AuthKeyRotator {
+func NewAuthKeyRotator(db *mongo.Database, repo repository.IdentityAuthRepository, keys *PublicKeyCache, vault *AuthKeyVault, owner string, cfg AuthKeyRotatorConfig, logger *logrus.Entry) *AuthKeyRotator {
 	if cfg.LockKey == "" {
 		cfg.LockKey = "identity:auth-key-rotation"
 	}
@@ -48,15 +48,22 @@ func NewAuthKeyRotator(db *mongo.Database, repo repository.IdentityAuthRepositor
 		cfg.RetireAfter = time.Hour
 	}
 
+	if cfg.Alg == "" {
+		cfg.Alg = AuthKeyAlgRS256
+	}
+
 	if logger == nil {
 		logger = logrus.WithField("scope", "auth-key-rotation")
 	}
 
 	return &AuthKeyRotator{
 		repo:             repo,
+		keys:             keys,
+		vault:            vault,
 		locker:           migrator.NewLocker(db, cfg.LockKey, owner, cfg.LeaseFor),
 		rotationInterval: cfg.RotationInterval,
 		retireAfter:      cfg.RetireAfter,
+		alg:              cfg.Alg,
 		logger:           logger,
 	}
 }
@@ -97,7 +104,7 @@ func (r *AuthKeyRotator) ensureActiveKey(ctx context.Context) error {
 		return err
 	}
 
-	return EnsureActiveKey(ctx, r.repo)
+	return EnsureActiveKey(ctx, r.repo, r.vault, r.alg)
 }
 
 func (r *AuthKeyRotator) rotateIfNeeded(ctx context.Context) error {
@@ -110,11 +117,15 @@ func (r *AuthKeyRotator) rotateIfNeeded(ctx context.Context) error {
 		return nil
 	}
 
-	newKey, err := buildAuthKey()
+	newKey, err := buildAuthKey(r.alg)
 	if err != nil {
 		return err
 	}
 
+	if err := r.vault.Seal(newKey); err != nil {
+		return err
+	}
+
 	if err := r.repo.InsertAuthKey(ctx, newKey); err != nil {
 		return err
 	}
@@ -124,8 +135,11 @@ func (r *AuthKeyRotator) rotateIfNeeded(ctx context.Context) error {
 		return err
 	}
 
+	r.keys.Invalidate()
+
 	r.logger.WithFields(logrus.Fields{
 		"new_kid": newKey.Kid,
+		"new_alg": newKey.Alg,
 		"old_kid": key.Kid,
 	}).Info("auth key rotation: rotated")
 
@@ -141,34 +155,9 @@ func (r *AuthKeyRotator) revokeRetired(ctx context.Context) error {
 	}
 
 	if count > 0 {
+		r.keys.Invalidate()
 		r.logger.WithField("count", count).Info("auth key rotation: revoked retiring keys")
 	}
 
 	return nil
 }
-
-func buildAuthKey() (*domain.AuthKey, error) {
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, err
-	}
-
-	privPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-
-	pubBytes, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
-	if err != nil {
-		return nil, err
-	}
-
-	pubPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})
-
-	return &domain.AuthKey{
-		Kid:           uuid.NewString(),
-		Alg:           "RS256",
-		Use:           "sig",
-		PrivateKeyPEM: string(privPem),
-		PublicKeyPEM:  string(pubPem),
-		Status:        domain.AuthKeyStatusActive,
-		CreatedAt:     time.Now().UTC(),
-	}, nil
-}
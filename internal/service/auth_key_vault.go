@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/repository"
+	"github.com/invenlore/identity.service/internal/settings"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuthKeyVault envelope-encrypts signing private keys with AES-GCM under a key-encryption key
+// (KEK). Each document records the KEK id it was sealed with, so the keyring can hold retired KEKs
+// while RewrapAuthKeys moves everything onto the current one. Without a keyring the vault passes
+// plaintext PEM through, which is how keys were stored before.
+type AuthKeyVault struct {
+	currentID string
+	keks      map[string]*secretBox
+}
+
+func NewAuthKeyVault(authSettings *settings.AuthSettings) (*AuthKeyVault, error) {
+	vault := &AuthKeyVault{
+		currentID: authSettings.KeyEncryptionKeyID,
+		keks:      make(map[string]*secretBox, len(authSettings.KeyEncryptionKeyring)),
+	}
+
+	for id, kek := range authSettings.KeyEncryptionKeyring {
+		box, err := newSecretBox(kek)
+		if err != nil {
+			return nil, fmt.Errorf("key encryption key %q rejected: %w", id, err)
+		}
+
+		vault.keks[id] = box
+	}
+
+	return vault, nil
+}
+
+func (v *AuthKeyVault) Enabled() bool {
+	return v.currentID != ""
+}
+
+// The kid is bound as associated data so a ciphertext cannot be moved onto another key document.
+func authKeyAssociatedData(key *domain.AuthKey) string {
+	return "auth-key:" + key.Kid
+}
+
+// Seal moves a plaintext private key into PrivateKeyCiphertext under the current KEK.
+func (v *AuthKeyVault) Seal(key *domain.AuthKey) error {
+	if !v.Enabled() || key.PrivateKeyPEM == "" {
+		return nil
+	}
+
+	sealed, err := v.keks[v.currentID].seal(key.PrivateKeyPEM, authKeyAssociatedData(key))
+	if err != nil {
+		return err
+	}
+
+	key.PrivateKeyCiphertext = sealed
+	key.KEKID = v.currentID
+	key.PrivateKeyPEM = ""
+
+	return nil
+}
+
+// PrivateKeyPEM returns the key's private PEM, decrypting it when it is sealed.
+func (v *AuthKeyVault) PrivateKeyPEM(key *domain.AuthKey) (string, error) {
+	if key.PrivateKeyCiphertext == "" {
+		if key.PrivateKeyPEM == "" {
+			return "", fmt.Errorf("auth key %q has no private key", key.Kid)
+		}
+
+		return key.PrivateKeyPEM, nil
+	}
+
+	box, ok := v.keks[key.KEKID]
+	if !ok {
+		return "", fmt.Errorf("auth key %q is sealed with unknown key encryption key %q", key.Kid, key.KEKID)
+	}
+
+	plain, err := box.open(key.PrivateKeyCiphertext, authKeyAssociatedData(key))
+	if err != nil {
+		return "", fmt.Errorf("auth key %q: %w", key.Kid, err)
+	}
+
+	return plain, nil
+}
+
+// Rewrap reseals key under the current KEK when it is plaintext or sealed with another KEK,
+// and reports whether it changed.
+func (v *AuthKeyVault) Rewrap(key *domain.AuthKey) (bool, error) {
+	if !v.Enabled() || (key.PrivateKeyCiphertext != "" && key.KEKID == v.currentID) {
+		return false, nil
+	}
+
+	if key.PrivateKeyCiphertext == "" && key.PrivateKeyPEM == "" {
+		return false, nil
+	}
+
+	plain, err := v.PrivateKeyPEM(key)
+	if err != nil {
+		return false, err
+	}
+
+	key.PrivateKeyPEM = plain
+	key.PrivateKeyCiphertext = ""
+	key.KEKID = ""
+
+	if err := v.Seal(key); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RewrapAuthKeys seals every stored private key under the current KEK: plaintext keys as well as
+// keys sealed with a retired KEK. Once it reports no failures the retired KEK can be dropped.
+func RewrapAuthKeys(ctx context.Context, repo repository.IdentityAuthRepository, vault *AuthKeyVault) (int, error) {
+	if !vault.Enabled() {
+		return 0, fmt.Errorf("no key encryption key is configured")
+	}
+
+	keys, err := repo.ListAuthKeysWithPrivateKey(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rewrapped := 0
+	for _, key := range keys {
+		previousKEKID := key.KEKID
+
+		changed, err := vault.Rewrap(key)
+		if err != nil {
+			return rewrapped, err
+		}
+
+		if !changed {
+			continue
+		}
+
+		// Matched on the previous KEK id; a concurrent rewrap that got there first is not an error.
+		if err := repo.UpdateAuthKeyPrivateKey(ctx, key.Id, key.PrivateKeyCiphertext, key.KEKID, previousKEKID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				continue
+			}
+
+			return rewrapped, err
+		}
+
+		rewrapped++
+	}
+
+	return rewrapped, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+)
+
+// Signing algorithms, as they appear in domain.AuthKey.Alg and the JWT/JWK "alg".
+const (
+	AuthKeyAlgRS256 = "RS256"
+	AuthKeyAlgES256 = "ES256"
+	AuthKeyAlgEdDSA = "EdDSA"
+)
+
+const rsaAuthKeyBits = 2048
+
+// accessTokenSigningAlgs lists every alg a stored key may have, so tokens signed before a change
+// of the configured algorithm keep verifying until their key is revoked.
+var accessTokenSigningAlgs = []string{AuthKeyAlgRS256, AuthKeyAlgES256, AuthKeyAlgEdDSA}
+
+// buildAuthKey generates a new active key for alg. Private keys are stored as PKCS#8; keys
+// created before EC/EdDSA support are PKCS#1 RSA and are still read by parseAuthPrivateKey.
+func buildAuthKey(alg string) (*domain.AuthKey, error) {
+	var (
+		priv crypto.Signer
+		err  error
+	)
+
+	switch alg {
+	case AuthKeyAlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, rsaAuthKeyBits)
+	case AuthKeyAlgES256:
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AuthKeyAlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("auth key algorithm %q is not supported", alg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AuthKey{
+		Kid:           uuid.NewString(),
+		Alg:           alg,
+		Use:           "sig",
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes})),
+		PublicKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})),
+		Status:        domain.AuthKeyStatusActive,
+		CreatedAt:     time.Now().UTC(),
+	}, nil
+}
+
+// authKeySigningMethod picks the JWT signing method from a key's Alg.
+func authKeySigningMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case AuthKeyAlgRS256:
+		return jwt.SigningMethodRS256, nil
+	case AuthKeyAlgES256:
+		return jwt.SigningMethodES256, nil
+	case AuthKeyAlgEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("auth key algorithm %q is not supported", alg)
+	}
+}
+
+// parseAuthPrivateKey takes the PEM from AuthKeyVault.PrivateKeyPEM, never the raw document field.
+func parseAuthPrivateKey(alg, privateKeyPEM string) (crypto.Signer, error) {
+	signer, err := parsePrivateKeyPEM([]byte(privateKeyPEM))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAuthKeyType(alg, signer.Public()); err != nil {
+		return nil, err
+	}
+
+	return signer, nil
+}
+
+func parsePrivateKeyPEM(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key")
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("private key block type %q is not supported", block.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("private key type %T is not supported", parsed)
+	}
+
+	return signer, nil
+}
+
+func parseAuthPublicKey(key *domain.AuthKey) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(key.PublicKeyPEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAuthKeyType(key.Alg, parsed); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// checkAuthKeyType rejects keys whose material does not fit their Alg, e.g. a P-384 key labelled ES256.
+func checkAuthKeyType(alg string, pub crypto.PublicKey) error {
+	if expected, err := authKeyAlgFor(pub); err != nil || expected != alg {
+		return fmt.Errorf("key type %T does not match algorithm %q", pub, alg)
+	}
+
+	return nil
+}
+
+// authKeyAlgFor maps a public key to the only alg this service signs with for its type.
+func authKeyAlgFor(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return AuthKeyAlgRS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve == elliptic.P256() {
+			return AuthKeyAlgES256, nil
+		}
+	case ed25519.PublicKey:
+		return AuthKeyAlgEdDSA, nil
+	}
+
+	return "", fmt.Errorf("key type %T is not supported, use RSA, P-256 or Ed25519", pub)
+}
+
+func buildJWK(key *domain.AuthKey) (*identity_v1.JWK, error) {
+	pub, err := parseAuthPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &identity_v1.JWK{
+		Kid: key.Kid,
+		Use: "sig",
+		Alg: key.Alg,
+	}
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		// RFC 7518 §6.2.1: coordinates are left-padded to the full field size.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	default:
+		return nil, fmt.Errorf("unsupported public key type")
+	}
+
+	return jwk, nil
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"google.golang.org/grpc/codes"
+)
+
+type loginAttempt struct {
+	name     string
+	email    string
+	password string
+}
+
+func enumerationAttempts() []loginAttempt {
+	return []loginAttempt{
+		{name: "unknown email", email: "nobody@example.com", password: "some password"},
+		{name: "wrong password", email: "known@example.com", password: "wrong password"},
+		{name: "unusable stored hash", email: "broken@example.com", password: "some password"},
+	}
+}
+
+func newEnumerationEnv(t *testing.T) *testAuthEnv {
+	t.Helper()
+
+	env := newTestAuthEnv(t, testAuthSettings())
+	env.addUser(t, "known@example.com", "correct horse battery")
+	env.repo.addUser(&domain.User{
+		Email:        "broken@example.com",
+		PasswordHash: "not-a-password-hash",
+		Status:       domain.UserStatusActive,
+	})
+
+	return env
+}
+
+func TestLoginAnswersUnknownEmailLikeWrongPassword(t *testing.T) {
+	env := newEnumerationEnv(t)
+	ctx := context.Background()
+
+	for _, attempt := range enumerationAttempts() {
+		before := env.svc.passwords.limiter.Stats().Completed
+
+		resp, code, err := env.svc.Login(ctx, &identity_v1.LoginRequest{Email: attempt.email, Password: attempt.password}, "test-agent", "192.0.2.1")
+		if resp != nil {
+			t.Fatalf("%s: got a login response", attempt.name)
+		}
+
+		if code != codes.Unauthenticated || err == nil || err.Error() != errInvalidCredentials.Error() {
+			t.Fatalf("%s: code %s, err %v; want %s, %q", attempt.name, code, err, codes.Unauthenticated, errInvalidCredentials)
+		}
+
+		// Exactly one KDF run per attempt, whether or not there was a real hash to check.
+		if runs := env.svc.passwords.limiter.Stats().Completed - before; runs != 1 {
+			t.Fatalf("%s: %d password hash runs, want 1", attempt.name, runs)
+		}
+	}
+}
+
+func TestPasswordVerifyOfMalformedHashSpendsAKDFRun(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	ctx := context.Background()
+
+	for _, encoded := range []string{"", "garbage", "$argon2id$v=19$m=x$", "$2b$04$short"} {
+		before := env.svc.passwords.limiter.Stats().Completed
+
+		ok, err := env.svc.passwords.verify(ctx, "password", encoded)
+		if ok || err != nil {
+			t.Fatalf("verify(%q) = %v, %v", encoded, ok, err)
+		}
+
+		if runs := env.svc.passwords.limiter.Stats().Completed - before; runs != 1 {
+			t.Fatalf("verify(%q): %d password hash runs, want 1", encoded, runs)
+		}
+	}
+}
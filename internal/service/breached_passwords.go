@@ -0,0 +1,75 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// BreachedPasswords is an in-memory set of SHA-1 password hashes, loaded from a file in the
+// Have I Been Pwned download format: one uppercase or lowercase hex hash per line, optionally
+// followed by ":count". Lines may be in any order. Use a top-N subset of the full dump; every
+// entry costs 20 bytes of memory.
+type BreachedPasswords struct {
+	hashes [][sha1.Size]byte
+}
+
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	hashes := make([][sha1.Size]byte, 0)
+	scanner := bufio.NewScanner(file)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hexHash, _, _ := strings.Cut(text, ":")
+
+		var hash [sha1.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(hexHash)); err != nil || n != sha1.Size {
+			return nil, fmt.Errorf("%s:%d: invalid sha-1 hash", path, line)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(hashes, func(a, b [sha1.Size]byte) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	return &BreachedPasswords{hashes: slices.Compact(hashes)}, nil
+}
+
+func (b *BreachedPasswords) Len() int {
+	return len(b.hashes)
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	hash := sha1.Sum([]byte(password))
+
+	_, found := slices.BinarySearchFunc(b.hashes, hash, func(a, target [sha1.Size]byte) int {
+		return bytes.Compare(a[:], target[:])
+	})
+
+	return found
+}
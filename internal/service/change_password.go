@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+// ChangePassword rotates the caller's password and revokes every other session. The caller's own
+// session survives; tokens issued without a sid claim cannot name it, so all sessions are revoked.
+func (s *identityAuthService) ChangePassword(ctx context.Context, accessToken, currentPassword, newPassword string) (int64, codes.Code, error) {
+	user, claims, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return 0, code, err
+	}
+
+	ok, err := s.passwords.verify(ctx, currentPassword, user.PasswordHash)
+	if err != nil {
+		return 0, passwordHashCode(err), err
+	}
+
+	if !ok {
+		return 0, codes.PermissionDenied, fmt.Errorf("current password is invalid")
+	}
+
+	if err := s.passwordPolicy.Check("new_password", newPassword, user.Email, user.Name); err != nil {
+		return 0, codes.InvalidArgument, err
+	}
+
+	if currentPassword == newPassword {
+		return 0, codes.InvalidArgument, fmt.Errorf("new password must differ from the current one")
+	}
+
+	passwordHash, err := s.passwords.hash(ctx, newPassword)
+	if err != nil {
+		return 0, passwordHashCode(err), err
+	}
+
+	if err := s.repo.UpdateUserPasswordHash(ctx, user.Id, passwordHash, time.Now().UTC()); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, codes.NotFound, fmt.Errorf("user not found")
+		}
+
+		return 0, codes.Internal, err
+	}
+
+	count, err := revokeUserSessions(ctx, s.repo, user.Id, claims.SessionID, domain.SessionRevokeReasonPasswordChange, s.accessTTL)
+	if err != nil {
+		return 0, codes.Internal, err
+	}
+
+	return count, codes.OK, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/notify"
+	"github.com/invenlore/identity.service/internal/settings"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+// EmailNotVerifiedError is returned by Login when the verification policy requires
+// a verified email, so the transport can tell clients to offer ResendVerification.
+type EmailNotVerifiedError struct{}
+
+func (e *EmailNotVerifiedError) Error() string {
+	return "email is not verified"
+}
+
+func (s *identityAuthService) VerifyEmail(ctx context.Context, token string) (codes.Code, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return codes.InvalidArgument, fmt.Errorf("token is required")
+	}
+
+	now := time.Now().UTC()
+
+	verification, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposeEmailVerification, hashRefreshToken(token), now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("verification token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	if err := s.repo.MarkUserEmailVerified(ctx, verification.UserID, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("verification token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	return codes.OK, nil
+}
+
+// ResendVerification answers the same way for unknown, already verified and unverified
+// addresses. The token is written and mailed in the background, so neither the answer nor the
+// response time tells which emails are registered.
+func (s *identityAuthService) ResendVerification(ctx context.Context, email, ip string) (codes.Code, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return codes.InvalidArgument, fmt.Errorf("email is required")
+	}
+
+	user, err := s.repo.FindUserByEmail(ctx, email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.OK, nil
+		}
+
+		return codes.Internal, err
+	}
+
+	if user.EmailVerifiedAt != nil || user.EffectiveStatus(time.Now()) != domain.UserStatusActive {
+		return codes.OK, nil
+	}
+
+	s.sendEmailVerification(user, ip)
+
+	return codes.OK, nil
+}
+
+func (s *identityAuthService) sendEmailVerification(user *domain.User, ip string) {
+	s.sendActionToken(user, domain.ActionTokenPurposeEmailVerification, s.emailVerificationTTL, ip, func(token string, expiresAt time.Time) notify.Message {
+		return notify.Message{
+			To:       user.Email,
+			Subject:  "Verify your email",
+			Template: "email_verification",
+			Data: map[string]string{
+				"name":       user.Name,
+				"token":      token,
+				"link":       actionLink(s.emailVerificationURL, token),
+				"expires_at": expiresAt.Format(time.RFC3339),
+			},
+		}
+	})
+}
+
+func (s *identityAuthService) checkEmailVerified(user *domain.User) error {
+	if s.emailVerificationPolicy != settings.EmailVerificationPolicyRequire || user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return &EmailNotVerifiedError{}
+}
@@ -0,0 +1,611 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/invenlore/core/pkg/config"
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/notify"
+	"github.com/invenlore/identity.service/internal/repository"
+	"github.com/invenlore/identity.service/internal/settings"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// fakeAuthRepo keeps the documents the auth flows under test touch in memory. Methods a test
+// does not expect to reach are left to the embedded nil interface and panic.
+type fakeAuthRepo struct {
+	repository.IdentityAuthRepository
+
+	mu          sync.Mutex
+	users       map[primitive.ObjectID]*domain.User
+	actions     []*domain.ActionToken
+	sessions    map[string]*domain.RefreshSession
+	revoked     []*domain.RevokedToken
+	credentials map[string]*domain.WebAuthnCredential
+
+	// beforeRotate, when set, runs once at the start of the next RotateRefreshSession, which is
+	// where a concurrent refresh can slip in between the read and the compare-and-swap.
+	beforeRotate func()
+	// beforeUseRecoveryCode does the same for UseUserRecoveryCode.
+	beforeUseRecoveryCode func()
+}
+
+func newFakeAuthRepo() *fakeAuthRepo {
+	return &fakeAuthRepo{
+		users:       make(map[primitive.ObjectID]*domain.User),
+		sessions:    make(map[string]*domain.RefreshSession),
+		credentials: make(map[string]*domain.WebAuthnCredential),
+	}
+}
+
+func (r *fakeAuthRepo) addUser(user *domain.User) *domain.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.Id.IsZero() {
+		user.Id = primitive.NewObjectID()
+	}
+
+	r.users[user.Id] = user
+
+	return user
+}
+
+func (r *fakeAuthRepo) FindUserByEmail(_ context.Context, email string) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *fakeAuthRepo) FindUserByID(_ context.Context, id primitive.ObjectID) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	copied := *user
+
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) ReplaceUserPasswordHash(_ context.Context, id primitive.ObjectID, previousHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.PasswordHash != previousHash {
+		return mongo.ErrNoDocuments
+	}
+
+	user.PasswordHash = newHash
+
+	return nil
+}
+
+func (r *fakeAuthRepo) InsertActionToken(_ context.Context, token *domain.ActionToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.Id = primitive.NewObjectID()
+	r.actions = append(r.actions, token)
+
+	return nil
+}
+
+func (r *fakeAuthRepo) findActionToken(purpose domain.ActionTokenPurpose, tokenHash string, now time.Time) *domain.ActionToken {
+	for _, token := range r.actions {
+		if token.Purpose == purpose && token.TokenHash == tokenHash && token.UsedAt == nil &&
+			token.ExpiresAt.After(now) && token.Attempts < domain.ActionTokenMaxAttempts {
+			return token
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepo) ConsumeActionToken(_ context.Context, purpose domain.ActionTokenPurpose, tokenHash string, now time.Time) (*domain.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := r.findActionToken(purpose, tokenHash, now)
+	if token == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	token.UsedAt = &now
+	copied := *token
+
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) FindActionToken(_ context.Context, purpose domain.ActionTokenPurpose, tokenHash string, now time.Time) (*domain.ActionToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := r.findActionToken(purpose, tokenHash, now)
+	if token == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	copied := *token
+
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) DeleteUserActionTokens(_ context.Context, userID primitive.ObjectID, purpose domain.ActionTokenPurpose) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.actions = slices.DeleteFunc(r.actions, func(token *domain.ActionToken) bool {
+		return token.UserID == userID && token.Purpose == purpose
+	})
+
+	return nil
+}
+
+func (r *fakeAuthRepo) RecordActionTokenFailure(_ context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.actions {
+		if token.Id == id {
+			token.Attempts++
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepo) ReplaceUserRecoveryCodes(_ context.Context, id primitive.ObjectID, recoveryCodes []domain.RecoveryCode, updatedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	user.RecoveryCodes = slices.Clone(recoveryCodes)
+	user.UpdatedAt = updatedAt
+
+	return nil
+}
+
+func (r *fakeAuthRepo) UseUserRecoveryCode(_ context.Context, userID primitive.ObjectID, codeID string, usedAt time.Time) error {
+	if hook := r.beforeUseRecoveryCode; hook != nil {
+		r.beforeUseRecoveryCode = nil
+		hook()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	// Replaced rather than modified in place, since users handed out earlier share the slice.
+	recoveryCodes := slices.Clone(user.RecoveryCodes)
+	for i := range recoveryCodes {
+		if recoveryCodes[i].ID == codeID && recoveryCodes[i].UsedAt == nil {
+			recoveryCodes[i].UsedAt = &usedAt
+			user.RecoveryCodes = recoveryCodes
+
+			return nil
+		}
+	}
+
+	return mongo.ErrNoDocuments
+}
+
+func (r *fakeAuthRepo) RecordUserTOTPStep(_ context.Context, id primitive.ObjectID, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.MFA == nil || user.MFA.TOTPLastStep >= step {
+		return mongo.ErrNoDocuments
+	}
+
+	mfa := *user.MFA
+	mfa.TOTPLastStep = step
+	user.MFA = &mfa
+
+	return nil
+}
+
+func (r *fakeAuthRepo) countActionTokens(purpose domain.ActionTokenPurpose) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, token := range r.actions {
+		if token.Purpose == purpose {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r *fakeAuthRepo) InsertRefreshSession(_ context.Context, session *domain.RefreshSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.SessionID] = session
+
+	return nil
+}
+
+// FindRefreshSession returns a copy, as a database read would, so later writes do not show
+// through a session the caller already holds.
+func (r *fakeAuthRepo) FindRefreshSession(_ context.Context, sessionID string) (*domain.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	found := *session
+	found.PreviousTokenHashes = slices.Clone(session.PreviousTokenHashes)
+	if session.Grace != nil {
+		grace := *session.Grace
+		found.Grace = &grace
+	}
+
+	return &found, nil
+}
+
+func (r *fakeAuthRepo) RotateRefreshSession(_ context.Context, sessionID, oldTokenHash, tokenHash string, expiresAt, updatedAt time.Time, grace *domain.RefreshGrace) error {
+	if hook := r.beforeRotate; hook != nil {
+		r.beforeRotate = nil
+		hook()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok || session.RefreshTokenHash != oldTokenHash || session.RevokedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	session.RefreshTokenHash = tokenHash
+	session.ExpiresAt = expiresAt
+	session.UpdatedAt = updatedAt
+	session.Generation++
+	session.PreviousTokenHashes = append(session.PreviousTokenHashes, oldTokenHash)
+	session.Grace = grace
+
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeRefreshSession(_ context.Context, sessionID string, revokedAt time.Time, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok {
+		return mongo.ErrNoDocuments
+	}
+
+	session.RevokedAt = &revokedAt
+	session.RevokedReason = reason
+
+	return nil
+}
+
+func (r *fakeAuthRepo) InsertRevokedTokens(_ context.Context, tokens []*domain.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoked = append(r.revoked, tokens...)
+
+	return nil
+}
+
+func (r *fakeAuthRepo) IsTokenRevoked(_ context.Context, jti, sid string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.revoked {
+		if !time.Now().Before(token.ExpiresAt) {
+			continue
+		}
+
+		if (token.Kind == domain.RevokedTokenKindJTI && jti != "" && token.Value == jti) ||
+			(token.Kind == domain.RevokedTokenKindSID && sid != "" && token.Value == sid) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeAuthRepo) InsertWebAuthnCredential(_ context.Context, credential *domain.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.credentials[credential.CredentialID]; ok {
+		return mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000}}}
+	}
+
+	credential.Id = primitive.NewObjectID()
+	r.credentials[credential.CredentialID] = credential
+
+	return nil
+}
+
+func (r *fakeAuthRepo) FindWebAuthnCredential(_ context.Context, credentialID string) (*domain.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	copied := *credential
+
+	return &copied, nil
+}
+
+func (r *fakeAuthRepo) ListUserWebAuthnCredentials(_ context.Context, userID primitive.ObjectID) ([]*domain.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []*domain.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			copied := *credential
+			result = append(result, &copied)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *fakeAuthRepo) credentialByID(id primitive.ObjectID) *domain.WebAuthnCredential {
+	for _, credential := range r.credentials {
+		if credential.Id == id {
+			return credential
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepo) UpdateWebAuthnSignCount(_ context.Context, id primitive.ObjectID, previousCount, signCount int64, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential := r.credentialByID(id)
+	if credential == nil || credential.SignCount != previousCount || credential.CloneDetectedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	credential.SignCount = signCount
+	credential.LastUsedAt = &usedAt
+
+	return nil
+}
+
+func (r *fakeAuthRepo) MarkWebAuthnCredentialCloned(_ context.Context, id primitive.ObjectID, detectedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	credential := r.credentialByID(id)
+	if credential == nil || credential.CloneDetectedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+
+	credential.CloneDetectedAt = &detectedAt
+
+	return nil
+}
+
+// fakeThrottleRepo counts failures without ever locking, so throttling never masks the answer under test.
+type fakeThrottleRepo struct {
+	mu       sync.Mutex
+	failures map[string]int64
+}
+
+func (r *fakeThrottleRepo) FindLoginThrottles(context.Context, []string) ([]*domain.LoginThrottle, error) {
+	return nil, nil
+}
+
+func (r *fakeThrottleRepo) RecordLoginFailure(_ context.Context, kind domain.LoginThrottleKind, value string, now, _ time.Time) (*domain.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures == nil {
+		r.failures = make(map[string]int64)
+	}
+
+	key := domain.LoginThrottleKey(kind, value)
+	r.failures[key]++
+
+	return &domain.LoginThrottle{Key: key, Kind: kind, Value: value, Failures: r.failures[key], LastFailedAt: now}, nil
+}
+
+func (r *fakeThrottleRepo) LockLoginThrottle(context.Context, string, time.Time) error {
+	return nil
+}
+
+func (r *fakeThrottleRepo) DeleteLoginThrottles(context.Context, []string) (int64, error) {
+	return 0, nil
+}
+
+func (r *fakeThrottleRepo) ListLoginThrottles(context.Context, bool, time.Time, int64) ([]*domain.LoginThrottle, error) {
+	return nil, nil
+}
+
+type recordingEvents struct {
+	mu     sync.Mutex
+	events []SecurityEvent
+}
+
+func (e *recordingEvents) Emit(_ context.Context, event SecurityEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.events = append(e.events, event)
+}
+
+func (e *recordingEvents) types() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	types := make([]string, 0, len(e.events))
+	for _, event := range e.events {
+		types = append(types, string(event.Type))
+	}
+
+	return types
+}
+
+type discardNotifier struct{}
+
+func (discardNotifier) Send(context.Context, notify.Message) error { return nil }
+
+// testAuthSettings uses small argon2 parameters; the tests compare flows, not hashing strength.
+func testAuthSettings() *settings.AuthSettings {
+	return &settings.AuthSettings{
+		RefreshGraceWindow:          10 * time.Second,
+		PasswordResetTTL:            30 * time.Minute,
+		EmailVerificationPolicy:     settings.EmailVerificationPolicyClaim,
+		EmailVerificationTTL:        24 * time.Hour,
+		MFAChallengeTTL:             5 * time.Minute,
+		WebAuthnRPID:                "id.example.com",
+		WebAuthnRPName:              "Example",
+		WebAuthnChallengeTTL:        5 * time.Minute,
+		WebAuthnLoginRateLimit:      30,
+		WebAuthnLoginRateWindow:     time.Minute,
+		LoginThrottleEmailThreshold: 5,
+		LoginThrottleIPThreshold:    20,
+		LoginThrottleBaseDelay:      time.Second,
+		LoginThrottleMaxDelay:       15 * time.Minute,
+		LoginThrottleWindow:         time.Hour,
+		PasswordMinLength:           8,
+		PasswordMaxLength:           256,
+		PasswordArgon2Time:          1,
+		PasswordArgon2MemoryKiB:     8 * 1024,
+		PasswordArgon2Threads:       1,
+		PasswordHashMaxConcurrent:   4,
+		PasswordHashQueueTimeout:    2 * time.Second,
+		SigningKeyAlg:               AuthKeyAlgES256,
+	}
+}
+
+type testAuthEnv struct {
+	svc    *identityAuthService
+	repo   *fakeAuthRepo
+	events *recordingEvents
+}
+
+func newTestAuthEnv(t *testing.T, authSettings *settings.AuthSettings) *testAuthEnv {
+	t.Helper()
+
+	keyDir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(keyDir, "test.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyProvider, err := NewFileKeyProvider(keyDir, "", time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newFakeAuthRepo()
+	events := &recordingEvents{}
+	authCfg := &config.AuthConfig{
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 24 * time.Hour,
+		JWTIssuer:       "identity-test",
+		JWTAudience:     "identity-test",
+	}
+
+	svc := NewIdentityAuthService(
+		repo,
+		NewIdentityUserBriefService(nil),
+		NewPublicKeyCache(keyProvider, time.Minute),
+		keyProvider,
+		events,
+		discardNotifier{},
+		NewLoginThrottler(&fakeThrottleRepo{}, authSettings),
+		NewPasswordPolicy(authSettings, nil),
+		NewPasswordHashLimiter(authSettings),
+		authCfg,
+		authSettings,
+	).(*identityAuthService)
+
+	return &testAuthEnv{svc: svc, repo: repo, events: events}
+}
+
+// addUser stores an active user whose password hash uses the service's own target parameters.
+func (e *testAuthEnv) addUser(t *testing.T, email, password string) *domain.User {
+	t.Helper()
+
+	hash, err := e.svc.passwords.hash(context.Background(), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e.repo.addUser(&domain.User{
+		Name:         strings.Split(email, "@")[0],
+		Email:        email,
+		Roles:        []string{"user"},
+		PasswordHash: hash,
+		Status:       domain.UserStatusActive,
+		CreatedAt:    time.Now().UTC(),
+	})
+}
+
+func (e *testAuthEnv) accessToken(t *testing.T, user *domain.User) string {
+	t.Helper()
+
+	token, _, err := e.svc.issueAccessToken(context.Background(), user, "test-session", []string{domain.AuthMethodPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
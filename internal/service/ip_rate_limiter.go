@@ -0,0 +1,75 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// ipRateLimiter allows limit requests per client IP in each fixed window. State is kept in
+// process, so with several replicas a client gets up to limit per replica; that is enough to stop
+// one address from flooding a collection, which is what it is for.
+type ipRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*ipRateWindow
+	sweptAt   time.Time
+	sweepSize int
+}
+
+type ipRateWindow struct {
+	startedAt time.Time
+	count     int
+}
+
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{
+		limit:     limit,
+		window:    window,
+		windows:   make(map[string]*ipRateWindow),
+		sweepSize: 1024,
+	}
+}
+
+// Allow counts a request from ip and reports whether it fits the current window. Requests without
+// a known address are not limited.
+func (l *ipRateLimiter) Allow(ip string, now time.Time) bool {
+	if ip == "" {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	w, ok := l.windows[ip]
+	if !ok || now.Sub(w.startedAt) >= l.window {
+		l.windows[ip] = &ipRateWindow{startedAt: now, count: 1}
+		return true
+	}
+
+	if w.count >= l.limit {
+		return false
+	}
+
+	w.count++
+
+	return true
+}
+
+// sweep drops finished windows once the map has grown, at most once per window.
+func (l *ipRateLimiter) sweep(now time.Time) {
+	if len(l.windows) < l.sweepSize || now.Sub(l.sweptAt) < l.window {
+		return
+	}
+
+	l.sweptAt = now
+
+	for ip, w := range l.windows {
+		if now.Sub(w.startedAt) >= l.window {
+			delete(l.windows, ip)
+		}
+	}
+}
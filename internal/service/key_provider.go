@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// KeyProvider is where access tokens get signed and where their verification keys come from.
+// MongoKeyProvider is the default; FileKeyProvider and ExternalKeyProvider serve deployments that
+// manage keys outside the auth_keys collection.
+type KeyProvider interface {
+	// Signer returns a signer for the active key, creating that key first where the provider can.
+	Signer(ctx context.Context) (TokenSigner, error)
+	// PublicKeys returns every key tokens may still be verified with, active and retiring.
+	PublicKeys(ctx context.Context) ([]*domain.AuthKey, error)
+}
+
+// TokenSigner signs JWS signing input with a single key. Sign returns the signature in JWS
+// encoding, e.g. r||s for ES256 rather than ASN.1 DER.
+type TokenSigner interface {
+	Kid() string
+	Alg() string
+	Sign(ctx context.Context, signingString string) ([]byte, error)
+}
+
+// signAccessToken builds the compact JWS itself so a TokenSigner never has to hand out key material.
+func signAccessToken(ctx context.Context, signer TokenSigner, claims jwt.Claims) (string, error) {
+	method, err := authKeySigningMethod(signer.Alg())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+
+	if kid := signer.Kid(); kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signingString, err := token.SigningString()
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := signer.Sign(ctx, signingString)
+	if err != nil {
+		return "", err
+	}
+
+	return signingString + "." + token.EncodeSegment(signature), nil
+}
+
+// localSigner signs in process with a parsed private key.
+type localSigner struct {
+	kid    string
+	alg    string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+func newLocalSigner(kid, alg string, key crypto.Signer) (*localSigner, error) {
+	method, err := authKeySigningMethod(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localSigner{kid: kid, alg: alg, method: method, key: key}, nil
+}
+
+func (s *localSigner) Kid() string { return s.kid }
+func (s *localSigner) Alg() string { return s.alg }
+
+func (s *localSigner) Sign(_ context.Context, signingString string) ([]byte, error) {
+	return s.method.Sign(signingString, s.key)
+}
+
+// MongoKeyProvider signs with the active key in auth_keys, unsealing it through the vault, and
+// creates one with alg when none exists. Rotation is left to AuthKeyRotator.
+type MongoKeyProvider struct {
+	repo  repository.IdentityAuthRepository
+	vault *AuthKeyVault
+	alg   string
+}
+
+func NewMongoKeyProvider(repo repository.IdentityAuthRepository, vault *AuthKeyVault, alg string) *MongoKeyProvider {
+	return &MongoKeyProvider{repo: repo, vault: vault, alg: alg}
+}
+
+func (p *MongoKeyProvider) Signer(ctx context.Context) (TokenSigner, error) {
+	key, err := p.repo.FindActiveAuthKey(ctx)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			if err := EnsureActiveKey(ctx, p.repo, p.vault, p.alg); err != nil {
+				return nil, err
+			}
+
+			key, err = p.repo.FindActiveAuthKey(ctx)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	privateKeyPEM, err := p.vault.PrivateKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parseAuthPrivateKey(key.Alg, privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLocalSigner(key.Kid, key.Alg, privateKey)
+}
+
+func (p *MongoKeyProvider) PublicKeys(ctx context.Context) ([]*domain.AuthKey, error) {
+	return p.repo.ListActivePublicKeys(ctx)
+}
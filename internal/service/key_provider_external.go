@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/invenlore/identity.service/internal/domain"
+)
+
+// es256IntegerBytes is the size of each of r and s in an ES256 JWS signature (RFC 7518 §3.4).
+const es256IntegerBytes = 32
+
+// ExternalSigner is the seam for KMS and HSM backends: the private key stays in the backend and
+// this service only ever sees signatures and the public key. No implementation ships here; a
+// deployment wires its own client into NewExternalKeyProvider.
+type ExternalSigner interface {
+	// Sign receives a SHA-256 digest for RS256 and ES256 and the full signing input for EdDSA,
+	// mirroring what KMS APIs expect. It returns the backend's native encoding: PKCS#1 v1.5 for
+	// RSA, ASN.1 DER for ECDSA, raw bytes for Ed25519.
+	Sign(ctx context.Context, keyID string, payload []byte) ([]byte, error)
+	// PublicKeyPEM returns the PKIX public key of keyID.
+	PublicKeyPEM(ctx context.Context, keyID string) (string, error)
+}
+
+// ExternalKeyProvider signs every token with one backend key. Rotation happens in the backend:
+// point kid at the new key and keep the old one listed in retiringKids until its tokens expire.
+type ExternalKeyProvider struct {
+	client       ExternalSigner
+	kid          string
+	alg          string
+	retiringKids []string
+}
+
+func NewExternalKeyProvider(client ExternalSigner, kid, alg string, retiringKids []string) (*ExternalKeyProvider, error) {
+	if _, err := authKeySigningMethod(alg); err != nil {
+		return nil, err
+	}
+
+	return &ExternalKeyProvider{client: client, kid: kid, alg: alg, retiringKids: retiringKids}, nil
+}
+
+func (p *ExternalKeyProvider) Signer(_ context.Context) (TokenSigner, error) {
+	return &externalTokenSigner{client: p.client, kid: p.kid, alg: p.alg}, nil
+}
+
+// PublicKeys fetches from the backend on every call; PublicKeyCache keeps that off the hot path.
+func (p *ExternalKeyProvider) PublicKeys(ctx context.Context) ([]*domain.AuthKey, error) {
+	keys := make([]*domain.AuthKey, 0, len(p.retiringKids)+1)
+
+	for i, kid := range append([]string{p.kid}, p.retiringKids...) {
+		publicKeyPEM, err := p.client.PublicKeyPEM(ctx, kid)
+		if err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+
+		status := domain.AuthKeyStatusRetiring
+		if i == 0 {
+			status = domain.AuthKeyStatusActive
+		}
+
+		key := &domain.AuthKey{Kid: kid, Alg: p.alg, Use: "sig", PublicKeyPEM: publicKeyPEM, Status: status}
+		if _, err := parseAuthPublicKey(key); err != nil {
+			return nil, fmt.Errorf("public key %q: %w", kid, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+type externalTokenSigner struct {
+	client ExternalSigner
+	kid    string
+	alg    string
+}
+
+func (s *externalTokenSigner) Kid() string { return s.kid }
+func (s *externalTokenSigner) Alg() string { return s.alg }
+
+func (s *externalTokenSigner) Sign(ctx context.Context, signingString string) ([]byte, error) {
+	payload := []byte(signingString)
+	if s.alg != AuthKeyAlgEdDSA {
+		digest := sha256.Sum256(payload)
+		payload = digest[:]
+	}
+
+	signature, err := s.client.Sign(ctx, s.kid, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.alg == AuthKeyAlgES256 {
+		return ecdsaDERToJWS(signature, es256IntegerBytes)
+	}
+
+	return signature, nil
+}
+
+// ecdsaDERToJWS converts an ASN.1 ECDSA signature into the fixed-size r||s form JWS uses.
+func ecdsaDERToJWS(der []byte, size int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("ecdsa signature invalid")
+	}
+
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, fmt.Errorf("ecdsa signature invalid")
+	}
+
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+
+	return out, nil
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/invenlore/identity.service/internal/domain"
+)
+
+// fakeExternalSigner answers like a KMS: it signs what it is given and returns the backend's
+// native encoding, DER for ECDSA.
+type fakeExternalSigner struct {
+	keys     map[string]*domain.AuthKey
+	payloads [][]byte
+	sign     func(payload []byte) ([]byte, error)
+}
+
+func newFakeExternalSigner(t *testing.T, alg string, kids ...string) *fakeExternalSigner {
+	t.Helper()
+
+	f := &fakeExternalSigner{keys: make(map[string]*domain.AuthKey, len(kids))}
+	for _, kid := range kids {
+		key, err := buildAuthKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key.Kid = kid
+		f.keys[kid] = key
+	}
+
+	return f
+}
+
+func (f *fakeExternalSigner) Sign(_ context.Context, keyID string, payload []byte) ([]byte, error) {
+	f.payloads = append(f.payloads, payload)
+
+	if f.sign != nil {
+		return f.sign(payload)
+	}
+
+	key, ok := f.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", keyID)
+	}
+
+	signer, err := parseAuthPrivateKey(key.Alg, key.PrivateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := signer.(type) {
+	case *ecdsa.PrivateKey:
+		return ecdsa.SignASN1(rand.Reader, k, payload)
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, payload)
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, payload), nil
+	default:
+		return nil, fmt.Errorf("unsupported key %T", signer)
+	}
+}
+
+func (f *fakeExternalSigner) PublicKeyPEM(_ context.Context, keyID string) (string, error) {
+	key, ok := f.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", keyID)
+	}
+
+	return key.PublicKeyPEM, nil
+}
+
+func TestExternalKeyProviderSignsVerifiableTokens(t *testing.T) {
+	const signingString = "eyJhbGciOiJFUzI1NiJ9.eyJzdWIiOiJ0ZXN0In0"
+
+	for _, alg := range accessTokenSigningAlgs {
+		t.Run(alg, func(t *testing.T) {
+			client := newFakeExternalSigner(t, alg, "kms-1")
+			ctx := context.Background()
+
+			provider, err := NewExternalKeyProvider(client, "kms-1", alg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signer, err := provider.Signer(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if signer.Kid() != "kms-1" || signer.Alg() != alg {
+				t.Fatalf("signer %s/%s, want kms-1/%s", signer.Kid(), signer.Alg(), alg)
+			}
+
+			signature, err := signer.Sign(ctx, signingString)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// KMS APIs take a SHA-256 digest for RSA and ECDSA but the whole message for Ed25519.
+			want := []byte(signingString)
+			if alg != AuthKeyAlgEdDSA {
+				digest := sha256.Sum256(want)
+				want = digest[:]
+			}
+
+			if len(client.payloads) != 1 || !bytes.Equal(client.payloads[0], want) {
+				t.Fatalf("backend received %x, want %x", client.payloads, want)
+			}
+
+			if alg == AuthKeyAlgES256 && len(signature) != 2*es256IntegerBytes {
+				t.Fatalf("ES256 signature is %d bytes, want the %d byte r||s form", len(signature), 2*es256IntegerBytes)
+			}
+
+			method, err := authKeySigningMethod(alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pub, err := parseAuthPublicKey(client.keys["kms-1"])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := method.Verify(signingString, signature, pub); err != nil {
+				t.Fatalf("signature does not verify as a JWS %s signature: %v", alg, err)
+			}
+		})
+	}
+}
+
+func TestExternalKeyProviderConvertsES256Signatures(t *testing.T) {
+	der := func(r, s *big.Int) []byte {
+		encoded, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return encoded
+	}
+
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 8*es256IntegerBytes)
+
+	tests := []struct {
+		name      string
+		signature []byte
+		err       error
+		want      []byte
+	}{
+		{name: "short integers are left-padded", signature: der(big.NewInt(1), big.NewInt(2)), want: append(append(make([]byte, 31), 1), append(make([]byte, 31), 2)...)},
+		{name: "backend error", err: fmt.Errorf("kms unavailable")},
+		{name: "not DER", signature: []byte("raw-signature")},
+		{name: "trailing bytes", signature: append(der(big.NewInt(1), big.NewInt(2)), 0)},
+		{name: "zero r", signature: der(big.NewInt(0), big.NewInt(2))},
+		{name: "negative s", signature: der(big.NewInt(1), big.NewInt(-2))},
+		{name: "oversized r", signature: der(tooLarge, big.NewInt(2))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeExternalSigner{sign: func([]byte) ([]byte, error) { return tt.signature, tt.err }}
+			signer := &externalTokenSigner{client: client, kid: "kms-1", alg: AuthKeyAlgES256}
+
+			signature, err := signer.Sign(context.Background(), "header.payload")
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("accepted backend signature %x as %x", tt.signature, signature)
+				}
+
+				return
+			}
+
+			if err != nil || !bytes.Equal(signature, tt.want) {
+				t.Fatalf("Sign = %x, %v; want %x", signature, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalKeyProviderPublicKeys(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeExternalSigner(t, AuthKeyAlgES256, "kms-2", "kms-1", "kms-0")
+
+	provider, err := NewExternalKeyProvider(client, "kms-2", AuthKeyAlgES256, []string{"kms-1", "kms-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := provider.PublicKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		kid    string
+		status domain.AuthKeyStatus
+	}{
+		{kid: "kms-2", status: domain.AuthKeyStatusActive},
+		{kid: "kms-1", status: domain.AuthKeyStatusRetiring},
+		{kid: "kms-0", status: domain.AuthKeyStatusRetiring},
+	}
+
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(want))
+	}
+
+	for i, key := range keys {
+		if key.Kid != want[i].kid || key.Status != want[i].status || key.Alg != AuthKeyAlgES256 || key.PublicKeyPEM != client.keys[key.Kid].PublicKeyPEM {
+			t.Fatalf("key %d: %s %s %s, want %s %s %s", i, key.Kid, key.Status, key.Alg, want[i].kid, want[i].status, AuthKeyAlgES256)
+		}
+
+		if key.PrivateKeyPEM != "" || key.PrivateKeyCiphertext != "" {
+			t.Fatalf("key %s carries private key material", key.Kid)
+		}
+	}
+
+	// The backend key must match the configured algorithm, and a missing retiring key is an error
+	// rather than a silently shorter JWKS.
+	mismatched, err := NewExternalKeyProvider(newFakeExternalSigner(t, AuthKeyAlgEdDSA, "kms-2"), "kms-2", AuthKeyAlgES256, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mismatched.PublicKeys(ctx); err == nil {
+		t.Fatalf("accepted an Ed25519 backend key for ES256")
+	}
+
+	missing, err := NewExternalKeyProvider(client, "kms-2", AuthKeyAlgES256, []string{"gone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := missing.PublicKeys(ctx); err == nil {
+		t.Fatalf("accepted a missing retiring key")
+	}
+
+	if _, err := NewExternalKeyProvider(client, "kms-2", "HS256", nil); err == nil {
+		t.Fatalf("accepted HS256")
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+const fileKeyExtension = ".pem"
+
+// FileKeyProvider signs with private keys mounted as files, e.g. a Kubernetes secret volume.
+// Every "<kid>.pem" in dir is a key (PKCS#8, PKCS#1 or SEC 1) whose alg follows from its type. The active
+// one is activeKid, or the lexicographically last kid when that is empty, so date-prefixed kids
+// rotate by adding a file. The rest are published as retiring until their file is removed.
+//
+// The directory is re-read at most once per reloadInterval when a call notices that file names,
+// sizes or modification times changed; a broken update is logged and the previous keys stay.
+type FileKeyProvider struct {
+	dir            string
+	activeKid      string
+	reloadInterval time.Duration
+	logger         *logrus.Entry
+
+	mu          sync.Mutex
+	fingerprint string
+	checkedAt   time.Time
+	signers     map[string]*localSigner
+	publicKeys  []*domain.AuthKey
+	active      *localSigner
+}
+
+func NewFileKeyProvider(dir, activeKid string, reloadInterval time.Duration, logger *logrus.Entry) (*FileKeyProvider, error) {
+	if reloadInterval <= 0 {
+		reloadInterval = 30 * time.Second
+	}
+
+	if logger == nil {
+		logger = logrus.WithField("scope", "file-key-provider")
+	}
+
+	p := &FileKeyProvider{
+		dir:            dir,
+		activeKid:      activeKid,
+		reloadInterval: reloadInterval,
+		logger:         logger,
+	}
+
+	fingerprint, err := p.currentFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.load(fingerprint); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *FileKeyProvider) Signer(_ context.Context) (TokenSigner, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reloadIfChanged()
+
+	return p.active, nil
+}
+
+func (p *FileKeyProvider) PublicKeys(_ context.Context) ([]*domain.AuthKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reloadIfChanged()
+
+	return slices.Clone(p.publicKeys), nil
+}
+
+// reloadIfChanged must be called with mu held.
+func (p *FileKeyProvider) reloadIfChanged() {
+	if time.Since(p.checkedAt) < p.reloadInterval {
+		return
+	}
+
+	p.checkedAt = time.Now()
+
+	fingerprint, err := p.currentFingerprint()
+	if err != nil {
+		p.logger.WithError(err).Error("key directory scan failed, keeping loaded keys")
+		return
+	}
+
+	if fingerprint == p.fingerprint {
+		return
+	}
+
+	if err := p.load(fingerprint); err != nil {
+		p.logger.WithError(err).Error("key reload failed, keeping loaded keys")
+		return
+	}
+
+	p.logger.WithField("active_kid", p.active.kid).WithField("keys", len(p.signers)).Info("keys reloaded")
+}
+
+// keyFiles lists "<kid>.pem" files; Kubernetes' hidden "..data" entries are skipped.
+func (p *FileKeyProvider) keyFiles() ([]string, error) {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, fileKeyExtension) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+func (p *FileKeyProvider) currentFingerprint() (string, error) {
+	names, err := p.keyFiles()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		// os.Stat follows the secret volume's symlinks to the file that actually changes.
+		info, err := os.Stat(filepath.Join(p.dir, name))
+		if err != nil {
+			return "", err
+		}
+
+		if info.IsDir() {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return b.String(), nil
+}
+
+// load must be called with mu held, or before p is shared.
+func (p *FileKeyProvider) load(fingerprint string) error {
+	names, err := p.keyFiles()
+	if err != nil {
+		return err
+	}
+
+	signers := make(map[string]*localSigner, len(names))
+	kids := make([]string, 0, len(names))
+
+	for _, name := range names {
+		kid := strings.TrimSuffix(name, fileKeyExtension)
+
+		raw, err := os.ReadFile(filepath.Join(p.dir, name))
+		if err != nil {
+			return err
+		}
+
+		signer, err := parseFileKey(kid, raw)
+		if err != nil {
+			return fmt.Errorf("key file %q: %w", name, err)
+		}
+
+		signers[kid] = signer
+		kids = append(kids, kid)
+	}
+
+	if len(kids) == 0 {
+		return fmt.Errorf("no %s key files in %s", fileKeyExtension, p.dir)
+	}
+
+	activeKid := p.activeKid
+	if activeKid == "" {
+		activeKid = kids[len(kids)-1]
+	}
+
+	active, ok := signers[activeKid]
+	if !ok {
+		return fmt.Errorf("active key %q has no key file in %s", activeKid, p.dir)
+	}
+
+	publicKeys := make([]*domain.AuthKey, 0, len(kids))
+	for _, kid := range kids {
+		pubBytes, err := x509.MarshalPKIXPublicKey(signers[kid].key.Public())
+		if err != nil {
+			return err
+		}
+
+		status := domain.AuthKeyStatusRetiring
+		if kid == activeKid {
+			status = domain.AuthKeyStatusActive
+		}
+
+		publicKeys = append(publicKeys, &domain.AuthKey{
+			Kid:          kid,
+			Alg:          signers[kid].alg,
+			Use:          "sig",
+			PublicKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes})),
+			Status:       status,
+		})
+	}
+
+	p.signers = signers
+	p.publicKeys = publicKeys
+	p.active = active
+	p.fingerprint = fingerprint
+
+	return nil
+}
+
+func parseFileKey(kid string, raw []byte) (*localSigner, error) {
+	key, err := parsePrivateKeyPEM(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := authKeyAlgFor(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return newLocalSigner(kid, alg, key)
+}
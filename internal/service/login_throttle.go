@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/repository"
+	"github.com/invenlore/identity.service/internal/settings"
+)
+
+// LoginThrottledError is returned while an email or client IP is locked out after repeated
+// failed logins; RetryAfter is surfaced to clients as gRPC RetryInfo.
+type LoginThrottledError struct {
+	Kind       domain.LoginThrottleKind
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many failed login attempts, retry later"
+}
+
+// LoginThrottler tracks failed logins per normalized email and per client IP. Once a key reaches
+// its threshold every further failure locks it for baseDelay doubled per extra failure, capped at
+// maxDelay, so sustained guessing ends up in a lockout of maxDelay at a time.
+type LoginThrottler struct {
+	repo           repository.IdentityLoginThrottleRepository
+	emailThreshold int64
+	ipThreshold    int64
+	baseDelay      time.Duration
+	maxDelay       time.Duration
+	window         time.Duration
+}
+
+func NewLoginThrottler(repo repository.IdentityLoginThrottleRepository, authSettings *settings.AuthSettings) *LoginThrottler {
+	return &LoginThrottler{
+		repo:           repo,
+		emailThreshold: authSettings.LoginThrottleEmailThreshold,
+		ipThreshold:    authSettings.LoginThrottleIPThreshold,
+		baseDelay:      authSettings.LoginThrottleBaseDelay,
+		maxDelay:       authSettings.LoginThrottleMaxDelay,
+		window:         authSettings.LoginThrottleWindow,
+	}
+}
+
+// Check returns a *LoginThrottledError if either key is currently locked.
+func (t *LoginThrottler) Check(ctx context.Context, email, ip string) error {
+	throttles, err := t.repo.FindLoginThrottles(ctx, loginThrottleKeys(email, ip))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	var locked *LoginThrottledError
+	for _, throttle := range throttles {
+		if throttle.LockedUntil == nil || !throttle.LockedUntil.After(now) {
+			continue
+		}
+
+		retryAfter := throttle.LockedUntil.Sub(now)
+		if locked == nil || retryAfter > locked.RetryAfter {
+			locked = &LoginThrottledError{Kind: throttle.Kind, RetryAfter: retryAfter}
+		}
+	}
+
+	if locked != nil {
+		return locked
+	}
+
+	return nil
+}
+
+func (t *LoginThrottler) RecordFailure(ctx context.Context, email, ip string) error {
+	now := time.Now().UTC()
+
+	if email = normalizeThrottleEmail(email); email != "" {
+		if err := t.recordFailure(ctx, domain.LoginThrottleKindEmail, email, t.emailThreshold, now); err != nil {
+			return err
+		}
+	}
+
+	if ip = strings.TrimSpace(ip); ip != "" {
+		if err := t.recordFailure(ctx, domain.LoginThrottleKindIP, ip, t.ipThreshold, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess forgets the email's failures. The IP counter is kept: one correct password
+// from an address says nothing about the other accounts it has been guessing.
+func (t *LoginThrottler) RecordSuccess(ctx context.Context, email string) error {
+	if email = normalizeThrottleEmail(email); email == "" {
+		return nil
+	}
+
+	_, err := t.repo.DeleteLoginThrottles(ctx, []string{domain.LoginThrottleKey(domain.LoginThrottleKindEmail, email)})
+	return err
+}
+
+func (t *LoginThrottler) Clear(ctx context.Context, email, ip string) (int64, error) {
+	keys := loginThrottleKeys(email, ip)
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("email or ip is required")
+	}
+
+	return t.repo.DeleteLoginThrottles(ctx, keys)
+}
+
+func (t *LoginThrottler) List(ctx context.Context, lockedOnly bool, limit int64) ([]*domain.LoginThrottle, error) {
+	return t.repo.ListLoginThrottles(ctx, lockedOnly, time.Now().UTC(), limit)
+}
+
+func (t *LoginThrottler) recordFailure(ctx context.Context, kind domain.LoginThrottleKind, value string, threshold int64, now time.Time) error {
+	throttle, err := t.repo.RecordLoginFailure(ctx, kind, value, now, now.Add(t.window))
+	if err != nil {
+		return err
+	}
+
+	delay := t.lockDelay(throttle.Failures, threshold)
+	if delay <= 0 {
+		return nil
+	}
+
+	return t.repo.LockLoginThrottle(ctx, throttle.Key, now.Add(delay))
+}
+
+func (t *LoginThrottler) lockDelay(failures, threshold int64) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+
+	delay := t.baseDelay
+	for i := threshold; i < failures && delay < t.maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, t.maxDelay)
+}
+
+func loginThrottleKeys(email, ip string) []string {
+	keys := make([]string, 0, 2)
+
+	if email = normalizeThrottleEmail(email); email != "" {
+		keys = append(keys, domain.LoginThrottleKey(domain.LoginThrottleKindEmail, email))
+	}
+
+	if ip = strings.TrimSpace(ip); ip != "" {
+		keys = append(keys, domain.LoginThrottleKey(domain.LoginThrottleKindIP, ip))
+	}
+
+	return keys
+}
+
+func normalizeThrottleEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
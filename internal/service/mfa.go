@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	mfaMethodTOTP         = "totp"
+	mfaMethodRecoveryCode = "recovery_code"
+)
+
+// BeginTOTPEnrollment stores a fresh pending secret; it only takes effect once ConfirmTOTPEnrollment
+// proves the authenticator app produces matching codes. Calling it again replaces the pending secret.
+func (s *identityAuthService) BeginTOTPEnrollment(ctx context.Context, accessToken string) (*identity_v1.BeginTOTPEnrollmentResponse, codes.Code, error) {
+	if s.mfaBox == nil {
+		return nil, codes.FailedPrecondition, fmt.Errorf("mfa is not configured")
+	}
+
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, code, err
+	}
+
+	if user.TOTPEnabled() {
+		return nil, codes.AlreadyExists, fmt.Errorf("totp is already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	sealed, err := s.mfaBox.seal(secret, user.Id.Hex())
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	if err := s.repo.SetUserPendingTOTPSecret(ctx, user.Id, sealed, time.Now().UTC()); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.NotFound, fmt.Errorf("user not found")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	return &identity_v1.BeginTOTPEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: totpURI(s.mfaIssuer, user.Email, secret),
+	}, codes.OK, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP and, if the user has no unused recovery codes left,
+// returns a fresh set so nobody ends up with a second factor and no way back in.
+func (s *identityAuthService) ConfirmTOTPEnrollment(ctx context.Context, accessToken, code string) ([]string, codes.Code, error) {
+	if s.mfaBox == nil {
+		return nil, codes.FailedPrecondition, fmt.Errorf("mfa is not configured")
+	}
+
+	user, _, errCode, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, errCode, err
+	}
+
+	if user.TOTPEnabled() {
+		return nil, codes.AlreadyExists, fmt.Errorf("totp is already enabled")
+	}
+
+	if user.MFA == nil || user.MFA.TOTPPendingSecret == "" {
+		return nil, codes.FailedPrecondition, fmt.Errorf("totp enrollment was not started")
+	}
+
+	secret, err := s.mfaBox.open(user.MFA.TOTPPendingSecret, user.Id.Hex())
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	now := time.Now().UTC()
+
+	step, ok := verifyTOTP(secret, code, now, 0)
+	if !ok {
+		return nil, codes.InvalidArgument, fmt.Errorf("totp code is invalid")
+	}
+
+	if err := s.repo.EnableUserTOTP(ctx, user.Id, user.MFA.TOTPPendingSecret, step, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Aborted, fmt.Errorf("totp enrollment changed concurrently, start again")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	if user.RemainingRecoveryCodes() > 0 {
+		return nil, codes.OK, nil
+	}
+
+	recoveryCodes, err := s.replaceRecoveryCodes(ctx, user)
+	if err != nil {
+		return nil, passwordHashCode(err), err
+	}
+
+	return recoveryCodes, codes.OK, nil
+}
+
+func (s *identityAuthService) startMFAChallenge(ctx context.Context, user *domain.User, ip string) (*identity_v1.LoginResponse, error) {
+	token, _, err := s.issueActionToken(ctx, user, domain.ActionTokenPurposeMFAChallenge, s.mfaChallengeTTL, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []string{mfaMethodTOTP}
+	if user.RemainingRecoveryCodes() > 0 {
+		methods = append(methods, mfaMethodRecoveryCode)
+	}
+
+	return &identity_v1.LoginResponse{
+		MfaRequired:       true,
+		MfaChallengeToken: token,
+		MfaMethods:        methods,
+	}, nil
+}
+
+// VerifyMFA completes a Login that returned an MFA challenge, with either a TOTP code or a recovery
+// code. A wrong TOTP code counts against the challenge, which stops matching after
+// domain.ActionTokenMaxAttempts failures, and a recovery code attempt uses the challenge up. Wrong
+// codes of either kind also count against the email and IP login throttles, so fresh challenges
+// from repeated password logins do not add up to unlimited guesses.
+func (s *identityAuthService) VerifyMFA(ctx context.Context, challengeToken, code, recoveryCode, userAgent, ip string) (*identity_v1.VerifyMFAResponse, codes.Code, error) {
+	challengeToken = strings.TrimSpace(challengeToken)
+	if challengeToken == "" || (strings.TrimSpace(code) == "" && strings.TrimSpace(recoveryCode) == "") {
+		return nil, codes.InvalidArgument, fmt.Errorf("challenge token and code are required")
+	}
+
+	if s.mfaBox == nil {
+		return nil, codes.FailedPrecondition, fmt.Errorf("mfa is not configured")
+	}
+
+	now := time.Now().UTC()
+	challengeHash := hashRefreshToken(challengeToken)
+
+	challenge, err := s.repo.FindActionToken(ctx, domain.ActionTokenPurposeMFAChallenge, challengeHash, now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("mfa challenge is invalid or expired")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	user, err := s.repo.FindUserByID(ctx, challenge.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("mfa challenge is invalid or expired")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	if errCode, err := s.checkLoginThrottle(ctx, user.Email, ip); err != nil {
+		return nil, errCode, err
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		return nil, codes.PermissionDenied, err
+	}
+
+	if strings.TrimSpace(recoveryCode) != "" {
+		// Spending a recovery code cannot be undone, so the challenge is consumed first: of
+		// concurrent attempts on one challenge only a single one gets to spend a code, and a
+		// wrong code costs the challenge.
+		if _, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposeMFAChallenge, challengeHash, now); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, codes.Unauthenticated, fmt.Errorf("mfa challenge is invalid or expired")
+			}
+
+			return nil, codes.Internal, err
+		}
+
+		if errCode, err := s.useRecoveryCode(ctx, user, recoveryCode, userAgent, ip); err != nil {
+			if errCode == codes.Unauthenticated {
+				s.loginFailed(ctx, user.Email, ip)
+			}
+
+			return nil, errCode, err
+		}
+
+		return s.completeMFALogin(ctx, user, userAgent, ip, recoveryAMR())
+	}
+
+	if !user.TOTPEnabled() {
+		return nil, codes.FailedPrecondition, fmt.Errorf("totp is not enabled")
+	}
+
+	secret, err := s.mfaBox.open(user.MFA.TOTPSecret, user.Id.Hex())
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	step, ok := verifyTOTP(secret, code, now, user.MFA.TOTPLastStep)
+	if !ok {
+		s.loginFailed(ctx, user.Email, ip)
+
+		if err := s.repo.RecordActionTokenFailure(ctx, challenge.Id); err != nil {
+			return nil, codes.Internal, err
+		}
+
+		return nil, codes.Unauthenticated, fmt.Errorf("mfa code is invalid")
+	}
+
+	if _, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposeMFAChallenge, challengeHash, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("mfa challenge is invalid or expired")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	if err := s.repo.RecordUserTOTPStep(ctx, user.Id, step); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("mfa code was already used")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	return s.completeMFALogin(ctx, user, userAgent, ip, []string{domain.AuthMethodPassword, domain.AuthMethodOTP, domain.AuthMethodMFA})
+}
+
+func (s *identityAuthService) completeMFALogin(ctx context.Context, user *domain.User, userAgent, ip string, amr []string) (*identity_v1.VerifyMFAResponse, codes.Code, error) {
+	s.loginSucceeded(ctx, user.Email)
+
+	resp, err := s.completeLogin(ctx, user, userAgent, ip, amr)
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	return &identity_v1.VerifyMFAResponse{
+		AccessToken:      resp.AccessToken,
+		RefreshToken:     resp.RefreshToken,
+		ExpiresInSeconds: resp.ExpiresInSeconds,
+		User:             resp.User,
+	}, codes.OK, nil
+}
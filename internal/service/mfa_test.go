@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"google.golang.org/grpc/codes"
+)
+
+func newMFAEnv(t *testing.T) *testAuthEnv {
+	t.Helper()
+
+	authSettings := testAuthSettings()
+	authSettings.MFAEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+	return newTestAuthEnv(t, authSettings)
+}
+
+// startChallenge returns a fresh MFA challenge for user, as a password Login would.
+func startChallenge(t *testing.T, env *testAuthEnv, user *domain.User) string {
+	t.Helper()
+
+	resp, err := env.svc.startMFAChallenge(context.Background(), user, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.MfaChallengeToken
+}
+
+func (e *testAuthEnv) verifyRecoveryCode(challenge, recoveryCode string) (*identity_v1.VerifyMFAResponse, codes.Code, error) {
+	return e.svc.VerifyMFA(context.Background(), challenge, "", recoveryCode, "test-agent", "192.0.2.1")
+}
+
+func TestVerifyMFARecoveryCodeChallengeCannotBeReplayedConcurrently(t *testing.T) {
+	env := newMFAEnv(t)
+	ctx := context.Background()
+	user := env.addUser(t, "recovery@example.com", "correct horse battery")
+
+	recoveryCodes, err := env.svc.replaceRecoveryCodes(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := startChallenge(t, env, user)
+
+	// The second request arrives while the first is spending its code.
+	var replayCode codes.Code
+	var replayErr error
+	env.repo.beforeUseRecoveryCode = func() {
+		_, replayCode, replayErr = env.verifyRecoveryCode(challenge, recoveryCodes[1])
+	}
+
+	if _, code, err := env.verifyRecoveryCode(challenge, recoveryCodes[0]); err != nil {
+		t.Fatalf("first attempt: %s, %v", code, err)
+	}
+
+	if replayCode != codes.Unauthenticated || replayErr == nil {
+		t.Fatalf("concurrent replay: %s, %v; want %s", replayCode, replayErr, codes.Unauthenticated)
+	}
+
+	stored, _ := env.repo.FindUserByID(ctx, user.Id)
+	if used := len(stored.RecoveryCodes) - stored.RemainingRecoveryCodes(); used != 1 {
+		t.Fatalf("%d recovery codes spent, want 1", used)
+	}
+}
+
+func TestVerifyMFAWrongRecoveryCodeUsesUpTheChallenge(t *testing.T) {
+	env := newMFAEnv(t)
+	ctx := context.Background()
+	user := env.addUser(t, "recovery@example.com", "correct horse battery")
+
+	recoveryCodes, err := env.svc.replaceRecoveryCodes(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge := startChallenge(t, env, user)
+
+	if _, code, _ := env.verifyRecoveryCode(challenge, "AAAA-AAAAA-AAAAA"); code != codes.Unauthenticated {
+		t.Fatalf("wrong code: %s, want %s", code, codes.Unauthenticated)
+	}
+
+	if _, code, _ := env.verifyRecoveryCode(challenge, recoveryCodes[0]); code != codes.Unauthenticated {
+		t.Fatalf("valid code on a used challenge: %s, want %s", code, codes.Unauthenticated)
+	}
+
+	if _, code, err := env.verifyRecoveryCode(startChallenge(t, env, user), recoveryCodes[0]); err != nil {
+		t.Fatalf("valid code on a fresh challenge: %s, %v", code, err)
+	}
+}
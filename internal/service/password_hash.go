@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/invenlore/identity.service/internal/settings"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// argon2Params are the tunable argon2id costs; memory is in KiB.
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+// legacyArgon2Params were hardcoded before hashes carried their own parameters. Every "salt:key"
+// hash in the users collection was produced with them.
+var legacyArgon2Params = argon2Params{time: 3, memory: 64 * 1024, threads: 2}
+
+// passwordHasher writes PHC strings ($argon2id$v=19$m=...,t=...,p=...$salt$key) with the target
+// parameters and verifies every scheme in passwordSchemes, so the target can be raised at any time
+// and existing or imported users are upgraded as they log in.
+// Every KDF run goes through limiter.
+type passwordHasher struct {
+	target  argon2Params
+	limiter *PasswordHashLimiter
+	// dummy is verified against whenever there is no real hash to check, so unknown emails and
+	// unusable stored hashes cost the same KDF run as a wrong password.
+	dummy passwordDigest
+}
+
+func newPasswordHasher(authSettings *settings.AuthSettings, limiter *PasswordHashLimiter) *passwordHasher {
+	h := &passwordHasher{
+		target: argon2Params{
+			time:    authSettings.PasswordArgon2Time,
+			memory:  authSettings.PasswordArgon2MemoryKiB,
+			threads: authSettings.PasswordArgon2Threads,
+		},
+		limiter: limiter,
+	}
+
+	// The plaintext is irrelevant; only the cost of checking it matters. encode always produces
+	// a hash that decodes.
+	h.dummy, _ = decodeArgon2PasswordHash(h.encode(rand.Text()))
+
+	return h
+}
+
+// argon2Digest is a decoded argon2id hash, either PHC or legacy "salt:key".
+type argon2Digest struct {
+	params argon2Params
+	salt   []byte
+	key    []byte
+	legacy bool
+}
+
+func (d *argon2Digest) matches(password string) bool {
+	key := argon2.IDKey([]byte(password), d.salt, d.params.time, d.params.memory, d.params.threads, uint32(len(d.key)))
+	return subtleCompare(key, d.key)
+}
+
+func (h *passwordHasher) hash(ctx context.Context, password string) (string, error) {
+	var encoded string
+	if err := h.limiter.run(ctx, func() { encoded = h.encode(password) }); err != nil {
+		return "", err
+	}
+
+	return encoded, nil
+}
+
+// encode bypasses the limiter; outside hash it is only meant for startup work.
+func (h *passwordHasher) encode(password string) string {
+	salt := make([]byte, argon2SaltLength)
+	_, _ = rand.Read(salt)
+	key := argon2.IDKey([]byte(password), salt, h.target.time, h.target.memory, h.target.threads, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.target.memory, h.target.time, h.target.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+// verify only fails with an error when no hashing slot was available. A malformed hash is a
+// mismatch, reached only after the same work as a real check so it cannot be told apart by latency.
+func (h *passwordHasher) verify(ctx context.Context, password, encoded string) (bool, error) {
+	_, digest, err := decodePasswordHash(encoded)
+	if err != nil {
+		return false, h.verifyDummy(ctx, password)
+	}
+
+	var ok bool
+	if err := h.limiter.run(ctx, func() { ok = digest.matches(password) }); err != nil {
+		return false, err
+	}
+
+	return ok, nil
+}
+
+// verifyDummy spends a verification's worth of work for a password that has nothing to match.
+func (h *passwordHasher) verifyDummy(ctx context.Context, password string) error {
+	return h.limiter.run(ctx, func() { h.dummy.matches(password) })
+}
+
+// needsRehash reports whether encoded is an imported scheme, legacy argon2id, or argon2id weaker
+// than the target in any dimension. Unparseable hashes never verify, so there is nothing to
+// upgrade them from.
+func (h *passwordHasher) needsRehash(encoded string) bool {
+	_, digest, err := decodePasswordHash(encoded)
+	if err != nil {
+		return false
+	}
+
+	decoded, ok := digest.(*argon2Digest)
+	if !ok {
+		return true
+	}
+
+	return decoded.legacy ||
+		decoded.params.time < h.target.time ||
+		decoded.params.memory < h.target.memory ||
+		decoded.params.threads < h.target.threads ||
+		len(decoded.salt) < argon2SaltLength ||
+		len(decoded.key) < argon2KeyLength
+}
+
+func decodeArgon2PasswordHash(encoded string) (passwordDigest, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, fmt.Errorf("password hash format invalid")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("password hash version invalid")
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, fmt.Errorf("password hash parameters invalid: %w", err)
+	}
+
+	if params.time == 0 || params.time > settings.MaxPasswordArgon2Time ||
+		params.threads == 0 || params.threads > settings.MaxPasswordArgon2Threads ||
+		params.memory < 8*uint32(params.threads) || params.memory > settings.MaxPasswordArgon2MemoryKiB {
+		return nil, fmt.Errorf("password hash parameters invalid")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("password hash salt invalid")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 || len(key) > passwordHashMaxKeyLength {
+		return nil, fmt.Errorf("password hash key invalid")
+	}
+
+	return &argon2Digest{params: params, salt: salt, key: key}, nil
+}
+
+func decodeLegacyArgon2PasswordHash(encoded string) (passwordDigest, error) {
+	parts := strings.Split(encoded, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("password hash format invalid")
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("password hash salt invalid")
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(key) == 0 || len(key) > passwordHashMaxKeyLength {
+		return nil, fmt.Errorf("password hash key invalid")
+	}
+
+	return &argon2Digest{params: legacyArgon2Params, salt: salt, key: key, legacy: true}, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/settings"
+	"golang.org/x/sync/semaphore"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrPasswordHashingBusy is returned when no hashing slot frees up within the queue timeout.
+var ErrPasswordHashingBusy = errors.New("password hashing is saturated, retry later")
+
+// Upper bounds of the hash latency histogram, in milliseconds; a final +Inf bucket is implied.
+var passwordHashLatencyBucketsMs = []int64{50, 100, 250, 500, 1000, 2500}
+
+// PasswordHashLimiter bounds how many KDF runs happen at once. A single argon2id run with the
+// default parameters allocates 64 MiB, so an unbounded burst of logins can exhaust the container.
+type PasswordHashLimiter struct {
+	sem           *semaphore.Weighted
+	maxConcurrent int64
+	queueTimeout  time.Duration
+
+	waiting   atomic.Int64
+	inFlight  atomic.Int64
+	rejected  atomic.Int64
+	completed atomic.Int64
+	hashNanos atomic.Int64
+	waitNanos atomic.Int64
+	buckets   []atomic.Int64
+}
+
+// PasswordHashStats is a point-in-time snapshot for sizing pods; it is published over expvar.
+type PasswordHashStats struct {
+	MaxConcurrent      int64            `json:"max_concurrent"`
+	Waiting            int64            `json:"waiting"`
+	InFlight           int64            `json:"in_flight"`
+	Rejected           int64            `json:"rejected"`
+	Completed          int64            `json:"completed"`
+	HashSecondsTotal   float64          `json:"hash_seconds_total"`
+	WaitSecondsTotal   float64          `json:"wait_seconds_total"`
+	HashLatencyBuckets map[string]int64 `json:"hash_latency_ms_buckets"`
+}
+
+func NewPasswordHashLimiter(authSettings *settings.AuthSettings) *PasswordHashLimiter {
+	return &PasswordHashLimiter{
+		sem:           semaphore.NewWeighted(authSettings.PasswordHashMaxConcurrent),
+		maxConcurrent: authSettings.PasswordHashMaxConcurrent,
+		queueTimeout:  authSettings.PasswordHashQueueTimeout,
+		buckets:       make([]atomic.Int64, len(passwordHashLatencyBucketsMs)+1),
+	}
+}
+
+// run waits for a slot, at most queueTimeout, and then calls fn while holding it.
+func (l *PasswordHashLimiter) run(ctx context.Context, fn func()) error {
+	waitCtx, cancel := context.WithTimeout(ctx, l.queueTimeout)
+	defer cancel()
+
+	queuedAt := time.Now()
+
+	l.waiting.Add(1)
+	err := l.sem.Acquire(waitCtx, 1)
+	l.waiting.Add(-1)
+	l.waitNanos.Add(int64(time.Since(queuedAt)))
+
+	if err != nil {
+		// The caller going away is not saturation; only our own timeout is.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		l.rejected.Add(1)
+		return ErrPasswordHashingBusy
+	}
+
+	defer l.sem.Release(1)
+
+	l.inFlight.Add(1)
+	startedAt := time.Now()
+
+	fn()
+
+	elapsed := time.Since(startedAt)
+	l.inFlight.Add(-1)
+	l.completed.Add(1)
+	l.hashNanos.Add(int64(elapsed))
+	l.buckets[passwordHashLatencyBucket(elapsed)].Add(1)
+
+	return nil
+}
+
+func passwordHashLatencyBucket(elapsed time.Duration) int {
+	ms := elapsed.Milliseconds()
+	for i, bound := range passwordHashLatencyBucketsMs {
+		if ms <= bound {
+			return i
+		}
+	}
+
+	return len(passwordHashLatencyBucketsMs)
+}
+
+// Stats returns cumulative histogram buckets keyed "le_<ms>" and "le_inf".
+func (l *PasswordHashLimiter) Stats() PasswordHashStats {
+	buckets := make(map[string]int64, len(l.buckets))
+
+	var cumulative int64
+	for i := range l.buckets {
+		cumulative += l.buckets[i].Load()
+
+		key := "le_inf"
+		if i < len(passwordHashLatencyBucketsMs) {
+			key = fmt.Sprintf("le_%d", passwordHashLatencyBucketsMs[i])
+		}
+
+		buckets[key] = cumulative
+	}
+
+	return PasswordHashStats{
+		MaxConcurrent:      l.maxConcurrent,
+		Waiting:            l.waiting.Load(),
+		InFlight:           l.inFlight.Load(),
+		Rejected:           l.rejected.Load(),
+		Completed:          l.completed.Load(),
+		HashSecondsTotal:   time.Duration(l.hashNanos.Load()).Seconds(),
+		WaitSecondsTotal:   time.Duration(l.waitNanos.Load()).Seconds(),
+		HashLatencyBuckets: buckets,
+	}
+}
+
+// passwordHashCode maps an error from passwordHasher to the status the caller should return.
+func passwordHashCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, ErrPasswordHashingBusy):
+		return codes.ResourceExhausted
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestPasswordHasher() *passwordHasher {
+	authSettings := testAuthSettings()
+	return newPasswordHasher(authSettings, NewPasswordHashLimiter(authSettings))
+}
+
+func TestPasswordHashRoundTrip(t *testing.T) {
+	h := newTestPasswordHasher()
+	ctx := context.Background()
+
+	encoded, err := h.hash(ctx, "correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, digest, err := decodePasswordHash(encoded)
+	if err != nil {
+		t.Fatalf("decode %q: %v", encoded, err)
+	}
+
+	decoded, ok := digest.(*argon2Digest)
+	if !ok || scheme != PasswordSchemeArgon2id {
+		t.Fatalf("decoded %q as %s %T", encoded, scheme, digest)
+	}
+
+	if decoded.legacy || decoded.params != h.target || len(decoded.salt) != argon2SaltLength || len(decoded.key) != argon2KeyLength {
+		t.Fatalf("decoded %+v, want the target parameters with a full salt and key", decoded)
+	}
+
+	for password, want := range map[string]bool{"correct horse battery": true, "correct horse batterz": false, "": false} {
+		if ok, err := h.verify(ctx, password, encoded); ok != want || err != nil {
+			t.Errorf("verify(%q) = %v, %v; want %v", password, ok, err, want)
+		}
+	}
+
+	if again, _ := h.hash(ctx, "correct horse battery"); again == encoded {
+		t.Fatalf("two hashes of one password share a salt")
+	}
+}
+
+func TestLegacyPasswordHashParses(t *testing.T) {
+	h := newTestPasswordHasher()
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("correct horse battery"), salt, legacyArgon2Params.time, legacyArgon2Params.memory, legacyArgon2Params.threads, argon2KeyLength)
+	encoded := base64.RawURLEncoding.EncodeToString(salt) + ":" + base64.RawURLEncoding.EncodeToString(key)
+
+	scheme, digest, err := decodePasswordHash(encoded)
+	if err != nil {
+		t.Fatalf("decode %q: %v", encoded, err)
+	}
+
+	decoded, ok := digest.(*argon2Digest)
+	if !ok || scheme != legacyArgon2Scheme.name {
+		t.Fatalf("decoded %q as %s %T", encoded, scheme, digest)
+	}
+
+	if !decoded.legacy || decoded.params != legacyArgon2Params || !bytes.Equal(decoded.salt, salt) || !bytes.Equal(decoded.key, key) {
+		t.Fatalf("decoded %+v, want the legacy parameters, salt and key", decoded)
+	}
+
+	if ok, err := h.verify(context.Background(), "correct horse battery", encoded); !ok || err != nil {
+		t.Fatalf("verify = %v, %v; want a match", ok, err)
+	}
+
+	for _, malformed := range []string{"onlysalt", "a:b:c", "c2FsdA:", "!!!:a2V5", "c2FsdA:!!!"} {
+		if _, _, err := decodePasswordHash(malformed); err == nil {
+			t.Errorf("decode %q succeeded", malformed)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	h := newTestPasswordHasher()
+	target := h.target
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, argon2SaltLength))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, argon2KeyLength))
+
+	phc := func(p argon2Params, salt, key string) string {
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memory, p.time, p.threads, salt, key)
+	}
+	with := func(change func(*argon2Params)) argon2Params {
+		p := target
+		change(&p)
+		return p
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    bool
+	}{
+		{name: "target parameters", encoded: phc(target, salt, key), want: false},
+		{name: "stronger parameters", encoded: phc(with(func(p *argon2Params) { p.time++; p.memory *= 2; p.threads++ }), salt, key), want: false},
+		{name: "weaker memory", encoded: phc(with(func(p *argon2Params) { p.time++; p.memory /= 2 }), salt, key), want: true},
+		{name: "short salt", encoded: phc(target, base64.RawStdEncoding.EncodeToString(make([]byte, 8)), key), want: true},
+		{name: "short key", encoded: phc(target, salt, base64.RawStdEncoding.EncodeToString(make([]byte, 16))), want: true},
+		{name: "legacy salt:key", encoded: base64.RawURLEncoding.EncodeToString(make([]byte, 16)) + ":" + base64.RawURLEncoding.EncodeToString(make([]byte, 32)), want: true},
+		{name: "imported bcrypt", encoded: string(bcryptHash), want: true},
+		{name: "unparseable", encoded: "$argon2id$v=19$garbage", want: false},
+		{name: "unknown scheme", encoded: "$md5$abc$def", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.needsRehash(tt.encoded); got != tt.want {
+				t.Fatalf("needsRehash(%q) = %v, want %v", tt.encoded, got, tt.want)
+			}
+		})
+	}
+
+	// The test target is the minimum for time and threads, so weaker ones need a stronger target.
+	strong := &passwordHasher{target: argon2Params{time: 3, memory: 64 * 1024, threads: 2}}
+	for name, p := range map[string]argon2Params{
+		"weaker time":    {time: 2, memory: 64 * 1024, threads: 2},
+		"weaker threads": {time: 3, memory: 64 * 1024, threads: 1},
+		"weaker memory":  {time: 3, memory: 32 * 1024, threads: 2},
+	} {
+		if !strong.needsRehash(phc(p, salt, key)) {
+			t.Errorf("%s: needsRehash = false, want true", name)
+		}
+	}
+
+	if strong.needsRehash(phc(strong.target, salt, key)) {
+		t.Errorf("equal parameters: needsRehash = true, want false")
+	}
+}
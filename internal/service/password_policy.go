@@ -0,0 +1,173 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/invenlore/identity.service/internal/settings"
+)
+
+// Personal-info fragments shorter than this are too common to reject passwords over.
+const passwordPersonalInfoMinLength = 3
+
+type PasswordPolicyViolation struct {
+	Rule        string
+	Description string
+}
+
+// PasswordPolicyError lists every rule a password broke so clients can show them all at once.
+// Field names the request field the password came from.
+type PasswordPolicyError struct {
+	Field      string
+	Violations []PasswordPolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		descriptions = append(descriptions, violation.Description)
+	}
+
+	return "password does not meet policy: " + strings.Join(descriptions, "; ")
+}
+
+// PasswordPolicy is applied wherever a new password is chosen: Register, ChangePassword and
+// ConfirmPasswordReset.
+type PasswordPolicy struct {
+	minLength            int
+	maxLength            int
+	requireUpper         bool
+	requireLower         bool
+	requireDigit         bool
+	requireSymbol        bool
+	disallowPersonalInfo bool
+	maxRepeated          int
+	breached             *BreachedPasswords
+}
+
+func NewPasswordPolicy(authSettings *settings.AuthSettings, breached *BreachedPasswords) *PasswordPolicy {
+	return &PasswordPolicy{
+		minLength:            authSettings.PasswordMinLength,
+		maxLength:            authSettings.PasswordMaxLength,
+		requireUpper:         authSettings.PasswordRequireUpper,
+		requireLower:         authSettings.PasswordRequireLower,
+		requireDigit:         authSettings.PasswordRequireDigit,
+		requireSymbol:        authSettings.PasswordRequireSymbol,
+		disallowPersonalInfo: authSettings.PasswordDisallowPersonalInfo,
+		maxRepeated:          authSettings.PasswordMaxRepeated,
+		breached:             breached,
+	}
+}
+
+// Check returns a *PasswordPolicyError, or nil. personalInfo holds the user's email and name.
+func (p *PasswordPolicy) Check(field, password string, personalInfo ...string) error {
+	violations := make([]PasswordPolicyViolation, 0)
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, PasswordPolicyViolation{Rule: rule, Description: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+
+	if length < p.minLength {
+		add("min_length", "password must be at least %d characters", p.minLength)
+	}
+
+	// The upper bound also keeps huge payloads from being fed to argon2.
+	if length > p.maxLength {
+		add("max_length", "password must be at most %d characters", p.maxLength)
+	}
+
+	if strings.TrimSpace(password) == "" {
+		add("blank", "password must not be blank")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.requireUpper && !hasUpper {
+		add("require_upper", "password must contain an uppercase letter")
+	}
+
+	if p.requireLower && !hasLower {
+		add("require_lower", "password must contain a lowercase letter")
+	}
+
+	if p.requireDigit && !hasDigit {
+		add("require_digit", "password must contain a digit")
+	}
+
+	if p.requireSymbol && !hasSymbol {
+		add("require_symbol", "password must contain a symbol")
+	}
+
+	if p.maxRepeated > 0 && longestRun(password) > p.maxRepeated {
+		add("max_repeated", "password must not repeat a character more than %d times in a row", p.maxRepeated)
+	}
+
+	if p.disallowPersonalInfo && containsPersonalInfo(password, personalInfo) {
+		add("personal_info", "password must not contain your email or name")
+	}
+
+	if p.breached != nil && p.breached.Contains(password) {
+		add("breached", "password appears in a known data breach")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return &PasswordPolicyError{Field: field, Violations: violations}
+}
+
+func longestRun(password string) int {
+	longest, current := 0, 0
+	var previous rune
+
+	for i, r := range []rune(password) {
+		if i > 0 && r == previous {
+			current++
+		} else {
+			current = 1
+		}
+
+		previous = r
+		longest = max(longest, current)
+	}
+
+	return longest
+}
+
+// containsPersonalInfo matches the email's local part and each word of the name, case-insensitively.
+func containsPersonalInfo(password string, personalInfo []string) bool {
+	lowered := strings.ToLower(password)
+
+	for _, info := range personalInfo {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if local, _, found := strings.Cut(info, "@"); found {
+			info = local
+		}
+
+		for _, fragment := range strings.FieldsFunc(info, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '.' || r == '_' || r == '-' || r == '+'
+		}) {
+			if utf8.RuneCountInString(fragment) >= passwordPersonalInfoMinLength && strings.Contains(lowered, fragment) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/notify"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+// RequestPasswordReset always succeeds for well-formed input, whether or not the email is known.
+// Every caller pays for the same single user lookup; the token is written and mailed in the
+// background, so response time does not reveal whether the address is registered either.
+func (s *identityAuthService) RequestPasswordReset(ctx context.Context, email, ip string) (codes.Code, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return codes.InvalidArgument, fmt.Errorf("email is required")
+	}
+
+	user, err := s.repo.FindUserByEmail(ctx, email)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.OK, nil
+		}
+
+		return codes.Internal, err
+	}
+
+	if user.EffectiveStatus(time.Now()) != domain.UserStatusActive {
+		return codes.OK, nil
+	}
+
+	s.sendActionToken(user, domain.ActionTokenPurposePasswordReset, s.passwordResetTTL, ip, func(token string, expiresAt time.Time) notify.Message {
+		return notify.Message{
+			To:       user.Email,
+			Subject:  "Reset your password",
+			Template: "password_reset",
+			Data: map[string]string{
+				"name":       user.Name,
+				"token":      token,
+				"link":       actionLink(s.passwordResetURL, token),
+				"expires_at": expiresAt.Format(time.RFC3339),
+			},
+		}
+	})
+
+	return codes.OK, nil
+}
+
+func (s *identityAuthService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) (codes.Code, error) {
+	if strings.TrimSpace(token) == "" || strings.TrimSpace(newPassword) == "" {
+		return codes.InvalidArgument, fmt.Errorf("token and new password are required")
+	}
+
+	now := time.Now().UTC()
+	tokenHash := hashRefreshToken(strings.TrimSpace(token))
+
+	// The policy needs the user's email and name, and a rejected password must not burn the
+	// token, so look it up first and consume it only once the new password is acceptable.
+	pending, err := s.repo.FindActionToken(ctx, domain.ActionTokenPurposePasswordReset, tokenHash, now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("reset token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	user, err := s.repo.FindUserByID(ctx, pending.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("reset token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	if err := s.passwordPolicy.Check("new_password", newPassword, user.Email, user.Name); err != nil {
+		return codes.InvalidArgument, err
+	}
+
+	// Hashed before the token is consumed so a saturated hasher does not burn it.
+	passwordHash, err := s.passwords.hash(ctx, newPassword)
+	if err != nil {
+		return passwordHashCode(err), err
+	}
+
+	resetToken, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposePasswordReset, tokenHash, now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("reset token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	if err := s.repo.UpdateUserPasswordHash(ctx, resetToken.UserID, passwordHash, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.InvalidArgument, fmt.Errorf("reset token is invalid or expired")
+		}
+
+		return codes.Internal, err
+	}
+
+	if _, err := revokeUserSessions(ctx, s.repo, resetToken.UserID, "", domain.SessionRevokeReasonPasswordReset, s.accessTTL); err != nil {
+		return codes.Internal, err
+	}
+
+	return codes.OK, nil
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PasswordSchemeArgon2id is the only scheme new hashes are written in; every other scheme is
+// accepted for imported users and replaced on their first successful login.
+const PasswordSchemeArgon2id = "argon2id"
+
+// Caps on the cost parameters of imported schemes, so a hostile import cannot make one login
+// arbitrarily expensive; argon2id is capped by the settings.MaxPasswordArgon2 constants.
+const (
+	bcryptMaxCost       = 15
+	pbkdf2MaxIterations = 10_000_000
+	scryptMaxLogN       = 20
+	scryptMaxR          = 32
+	scryptMaxP          = 16
+	// scryptMaxMemory bounds the 128*r*N bytes a single scrypt run allocates.
+	scryptMaxMemory = 1 << 30
+	// passwordHashMaxKeyLength bounds stored keys, since PBKDF2 repeats every iteration for each
+	// 32-byte block of output.
+	passwordHashMaxKeyLength = 64
+)
+
+// passwordDigest is a decoded hash that can check a candidate password.
+type passwordDigest interface {
+	matches(password string) bool
+}
+
+type passwordScheme struct {
+	name   string
+	decode func(encoded string) (passwordDigest, error)
+}
+
+// passwordSchemes is keyed by hash prefix, see passwordHashPrefix.
+var passwordSchemes = map[string]passwordScheme{
+	"$argon2id$":      {name: PasswordSchemeArgon2id, decode: decodeArgon2PasswordHash},
+	"$2a$":            {name: "bcrypt", decode: decodeBcryptPasswordHash},
+	"$2b$":            {name: "bcrypt", decode: decodeBcryptPasswordHash},
+	"$2y$":            {name: "bcrypt", decode: decodeBcryptPasswordHash},
+	"$pbkdf2-sha256$": {name: "pbkdf2-sha256", decode: decodePasslibPBKDF2PasswordHash},
+	"pbkdf2_sha256$":  {name: "pbkdf2-sha256", decode: decodeDjangoPBKDF2PasswordHash},
+	"$scrypt$":        {name: "scrypt", decode: decodeScryptPasswordHash},
+}
+
+// legacyArgon2Scheme covers "salt:key" hashes, which predate prefixes.
+var legacyArgon2Scheme = passwordScheme{name: PasswordSchemeArgon2id, decode: decodeLegacyArgon2PasswordHash}
+
+// passwordHashPrefix returns the identifier up to and including its closing "$": "$2b$" for
+// modular crypt strings, "pbkdf2_sha256$" for Django ones.
+func passwordHashPrefix(encoded string) string {
+	start := 0
+	if strings.HasPrefix(encoded, "$") {
+		start = 1
+	}
+
+	end := strings.IndexByte(encoded[start:], '$')
+	if end < 0 {
+		return ""
+	}
+
+	return encoded[:start+end+1]
+}
+
+func decodePasswordHash(encoded string) (string, passwordDigest, error) {
+	scheme := legacyArgon2Scheme
+
+	if prefix := passwordHashPrefix(encoded); prefix != "" {
+		registered, ok := passwordSchemes[prefix]
+		if !ok {
+			return "", nil, fmt.Errorf("password hash scheme %q is not supported", prefix)
+		}
+
+		scheme = registered
+	}
+
+	digest, err := scheme.decode(encoded)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return scheme.name, digest, nil
+}
+
+// PasswordHashScheme validates encoded without running the KDF and returns its scheme name.
+func PasswordHashScheme(encoded string) (string, error) {
+	scheme, _, err := decodePasswordHash(encoded)
+	return scheme, err
+}
+
+type bcryptDigest []byte
+
+func (d bcryptDigest) matches(password string) bool {
+	return bcrypt.CompareHashAndPassword(d, []byte(password)) == nil
+}
+
+func decodeBcryptPasswordHash(encoded string) (passwordDigest, error) {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("bcrypt hash invalid: %w", err)
+	}
+
+	if cost > bcryptMaxCost {
+		return nil, fmt.Errorf("bcrypt hash cost invalid")
+	}
+
+	return bcryptDigest(encoded), nil
+}
+
+type pbkdf2Digest struct {
+	iterations int
+	salt       []byte
+	key        []byte
+}
+
+func (d *pbkdf2Digest) matches(password string) bool {
+	key, err := pbkdf2.Key(sha256.New, password, d.salt, d.iterations, len(d.key))
+	if err != nil {
+		return false
+	}
+
+	return subtleCompare(key, d.key)
+}
+
+// decodePasslibPBKDF2PasswordHash reads $pbkdf2-sha256$rounds$salt$checksum, where salt and
+// checksum use passlib's base64 variant with "." in place of "+".
+func decodePasslibPBKDF2PasswordHash(encoded string) (passwordDigest, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return nil, fmt.Errorf("pbkdf2 hash format invalid")
+	}
+
+	iterations, err := parsePBKDF2Iterations(parts[2])
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := decodePasslibBase64(parts[3])
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("pbkdf2 hash salt invalid")
+	}
+
+	key, err := decodePasslibBase64(parts[4])
+	if err != nil || len(key) == 0 || len(key) > passwordHashMaxKeyLength {
+		return nil, fmt.Errorf("pbkdf2 hash key invalid")
+	}
+
+	return &pbkdf2Digest{iterations: iterations, salt: salt, key: key}, nil
+}
+
+// decodeDjangoPBKDF2PasswordHash reads pbkdf2_sha256$iterations$salt$hash, where the salt is used
+// as-is and the hash is padded standard base64.
+func decodeDjangoPBKDF2PasswordHash(encoded string) (passwordDigest, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("pbkdf2 hash format invalid")
+	}
+
+	iterations, err := parsePBKDF2Iterations(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if parts[2] == "" {
+		return nil, fmt.Errorf("pbkdf2 hash salt invalid")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 || len(key) > passwordHashMaxKeyLength {
+		return nil, fmt.Errorf("pbkdf2 hash key invalid")
+	}
+
+	return &pbkdf2Digest{iterations: iterations, salt: []byte(parts[2]), key: key}, nil
+}
+
+func parsePBKDF2Iterations(raw string) (int, error) {
+	iterations, err := strconv.Atoi(raw)
+	if err != nil || iterations < 1 || iterations > pbkdf2MaxIterations {
+		return 0, fmt.Errorf("pbkdf2 hash iterations invalid")
+	}
+
+	return iterations, nil
+}
+
+type scryptDigest struct {
+	n, r, p int
+	salt    []byte
+	key     []byte
+}
+
+func (d *scryptDigest) matches(password string) bool {
+	key, err := scrypt.Key([]byte(password), d.salt, d.n, d.r, d.p, len(d.key))
+	if err != nil {
+		return false
+	}
+
+	return subtleCompare(key, d.key)
+}
+
+// decodeScryptPasswordHash reads passlib's $scrypt$ln=..,r=..,p=..$salt$checksum.
+func decodeScryptPasswordHash(encoded string) (passwordDigest, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return nil, fmt.Errorf("scrypt hash format invalid")
+	}
+
+	var logN, r, p int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &logN, &r, &p); err != nil {
+		return nil, fmt.Errorf("scrypt hash parameters invalid: %w", err)
+	}
+
+	if logN < 1 || logN > scryptMaxLogN || r < 1 || r > scryptMaxR || p < 1 || p > scryptMaxP || 128*r<<logN > scryptMaxMemory {
+		return nil, fmt.Errorf("scrypt hash parameters invalid")
+	}
+
+	salt, err := decodePasslibBase64(parts[3])
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("scrypt hash salt invalid")
+	}
+
+	key, err := decodePasslibBase64(parts[4])
+	if err != nil || len(key) == 0 || len(key) > passwordHashMaxKeyLength {
+		return nil, fmt.Errorf("scrypt hash key invalid")
+	}
+
+	return &scryptDigest{n: 1 << logN, r: r, p: p, salt: salt, key: key}, nil
+}
+
+func decodePasslibBase64(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(strings.TrimRight(value, "="), ".", "+"))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashSchemeRejectsExcessiveCosts(t *testing.T) {
+	bcrypt4, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	longKey := base64.RawStdEncoding.EncodeToString(make([]byte, 4096))
+
+	accepted := []string{
+		string(bcrypt4),
+		"$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key,
+		"$scrypt$ln=16,r=8,p=1$" + salt + "$" + key,
+		"$pbkdf2-sha256$29000$" + salt + "$" + key,
+	}
+
+	rejected := []string{
+		strings.Replace(string(bcrypt4), "$04$", "$16$", 1),
+		"$argon2id$v=19$m=4194304,t=3,p=2$" + salt + "$" + key,
+		"$argon2id$v=19$m=65536,t=1000,p=2$" + salt + "$" + key,
+		"$argon2id$v=19$m=65536,t=3,p=200$" + salt + "$" + key,
+		"$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + longKey,
+		"$scrypt$ln=20,r=32,p=1$" + salt + "$" + key,
+		"$scrypt$ln=14,r=8,p=1000$" + salt + "$" + key,
+		"$scrypt$ln=14,r=100000,p=1$" + salt + "$" + key,
+		"$pbkdf2-sha256$1000$" + salt + "$" + longKey,
+		"pbkdf2_sha256$1000$salt$" + base64.StdEncoding.EncodeToString(make([]byte, 4096)),
+	}
+
+	for _, encoded := range accepted {
+		if _, err := PasswordHashScheme(encoded); err != nil {
+			t.Errorf("PasswordHashScheme(%.40q): %v", encoded, err)
+		}
+	}
+
+	for _, encoded := range rejected {
+		if scheme, err := PasswordHashScheme(encoded); err == nil {
+			t.Errorf("PasswordHashScheme(%.40q) accepted as %s", encoded, scheme)
+		}
+	}
+}
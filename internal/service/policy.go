@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"github.com/invenlore/identity.service/internal/repository"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+type IdentityPolicyService interface {
+	Authorize(ctx context.Context, subject, resource, action string) (*identity_v1.AuthorizeResponse, codes.Code, error)
+}
+
+type identityPolicyService struct {
+	repo repository.IdentityPolicyRepository
+}
+
+func NewIdentityPolicyService(repo repository.IdentityPolicyRepository) IdentityPolicyService {
+	return &identityPolicyService{repo: repo}
+}
+
+func (s *identityPolicyService) Authorize(ctx context.Context, subject, resource, action string) (*identity_v1.AuthorizeResponse, codes.Code, error) {
+	userID, err := primitive.ObjectIDFromHex(subject)
+	if err != nil {
+		return nil, codes.InvalidArgument, fmt.Errorf("converting string to ObjectID failed: %v", err)
+	}
+
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.NotFound, fmt.Errorf("user for id (%s) is not found", subject)
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	// Access tokens outlive a suspension or disable until they expire, so the account is checked here too.
+	if status := user.EffectiveStatus(time.Now()); status != domain.UserStatusActive {
+		return &identity_v1.AuthorizeResponse{
+			Allowed:     false,
+			MatchedRule: fmt.Sprintf("%s:account %s", domain.PermissionEffectDeny, status),
+		}, codes.OK, nil
+	}
+
+	roles, err := s.repo.FindRolesByNames(ctx, user.Roles)
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	allowed, rule := evaluatePolicy(roles, resource, action)
+
+	return &identity_v1.AuthorizeResponse{
+		Allowed:     allowed,
+		MatchedRule: rule,
+	}, codes.OK, nil
+}
+
+// evaluatePolicy is deny-by-default: any matching deny rule wins over allow
+// rules, and the first matching rule (by role name) is reported back.
+func evaluatePolicy(roles []*domain.Role, resource, action string) (bool, string) {
+	sorted := make([]*domain.Role, len(roles))
+	copy(sorted, roles)
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	allowRule := ""
+
+	for _, role := range sorted {
+		for _, perm := range role.Permissions {
+			if !permissionMatches(perm.Resource, resource) || !permissionMatches(perm.Action, action) {
+				continue
+			}
+
+			if perm.Effect == domain.PermissionEffectDeny {
+				return false, formatPolicyRule(role.Name, perm)
+			}
+
+			if allowRule == "" {
+				allowRule = formatPolicyRule(role.Name, perm)
+			}
+		}
+	}
+
+	return allowRule != "", allowRule
+}
+
+func permissionMatches(pattern, value string) bool {
+	if pattern == domain.PermissionWildcard || pattern == value {
+		return true
+	}
+
+	if prefix, ok := strings.CutSuffix(pattern, ":"+domain.PermissionWildcard); ok {
+		return strings.HasPrefix(value, prefix+":")
+	}
+
+	return false
+}
+
+func formatPolicyRule(role string, perm domain.Permission) string {
+	effect := perm.Effect
+	if effect == "" {
+		effect = domain.PermissionEffectAllow
+	}
+
+	return fmt.Sprintf("%s:%s %s/%s", effect, role, perm.Resource, perm.Action)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+type fakePolicyRepo struct {
+	users map[primitive.ObjectID]*domain.User
+	roles map[string]*domain.Role
+}
+
+func (r *fakePolicyRepo) FindUserByID(_ context.Context, id primitive.ObjectID) (*domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	return user, nil
+}
+
+// FindRolesByNames, like the repository, silently skips names without a role document.
+func (r *fakePolicyRepo) FindRolesByNames(_ context.Context, names []string) ([]*domain.Role, error) {
+	roles := make([]*domain.Role, 0, len(names))
+	for _, name := range names {
+		if role, ok := r.roles[name]; ok {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, nil
+}
+
+func allow(resource, action string) domain.Permission {
+	return domain.Permission{Resource: resource, Action: action, Effect: domain.PermissionEffectAllow}
+}
+
+func deny(resource, action string) domain.Permission {
+	return domain.Permission{Resource: resource, Action: action, Effect: domain.PermissionEffectDeny}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	roles := map[string]*domain.Role{
+		"admin":   {Name: "admin", Permissions: []domain.Permission{allow("*", "*")}},
+		"editor":  {Name: "editor", Permissions: []domain.Permission{allow("articles:*", "write"), {Resource: "articles", Action: "read"}}},
+		"blocked": {Name: "blocked", Permissions: []domain.Permission{deny("articles:drafts", "*")}},
+		"reader":  {Name: "reader", Permissions: []domain.Permission{allow("articles", "read")}},
+	}
+
+	pick := func(names ...string) []*domain.Role {
+		picked := make([]*domain.Role, 0, len(names))
+		for _, name := range names {
+			picked = append(picked, roles[name])
+		}
+
+		return picked
+	}
+
+	tests := []struct {
+		name     string
+		roles    []*domain.Role
+		resource string
+		action   string
+		allowed  bool
+		rule     string
+	}{
+		{name: "no roles", roles: nil, resource: "articles", action: "read", allowed: false, rule: ""},
+		{name: "exact allow", roles: pick("reader"), resource: "articles", action: "read", allowed: true, rule: "allow:reader articles/read"},
+		{name: "no matching rule", roles: pick("reader"), resource: "articles", action: "write", allowed: false, rule: ""},
+		{name: "empty effect allows", roles: pick("editor"), resource: "articles", action: "read", allowed: true, rule: "allow:editor articles/read"},
+		{name: "full wildcard", roles: pick("admin"), resource: "billing", action: "refund", allowed: true, rule: "allow:admin */*"},
+		{name: "prefix wildcard", roles: pick("editor"), resource: "articles:drafts", action: "write", allowed: true, rule: "allow:editor articles:*/write"},
+		{name: "prefix wildcard needs the separator", roles: pick("editor"), resource: "articlesx", action: "write", allowed: false, rule: ""},
+		{name: "prefix wildcard does not match the bare prefix", roles: pick("editor"), resource: "articles", action: "write", allowed: false, rule: ""},
+		{name: "deny wins over wildcard allow", roles: pick("admin", "blocked"), resource: "articles:drafts", action: "write", allowed: false, rule: "deny:blocked articles:drafts/*"},
+		{name: "deny wins regardless of role order", roles: pick("blocked", "editor"), resource: "articles:drafts", action: "write", allowed: false, rule: "deny:blocked articles:drafts/*"},
+		{name: "deny outside its scope", roles: pick("admin", "blocked"), resource: "articles", action: "read", allowed: true, rule: "allow:admin */*"},
+		{name: "first allow by role name", roles: pick("reader", "admin"), resource: "articles", action: "read", allowed: true, rule: "allow:admin */*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, rule := evaluatePolicy(tt.roles, tt.resource, tt.action)
+			if allowed != tt.allowed || rule != tt.rule {
+				t.Fatalf("evaluatePolicy = %v, %q; want %v, %q", allowed, rule, tt.allowed, tt.rule)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	active := &domain.User{Id: primitive.NewObjectID(), Status: domain.UserStatusActive, Roles: []string{"ghost", "reader"}}
+	unknownOnly := &domain.User{Id: primitive.NewObjectID(), Status: domain.UserStatusActive, Roles: []string{"ghost"}}
+	disabled := &domain.User{Id: primitive.NewObjectID(), Status: domain.UserStatusDisabled, Roles: []string{"reader"}}
+
+	repo := &fakePolicyRepo{
+		users: map[primitive.ObjectID]*domain.User{active.Id: active, unknownOnly.Id: unknownOnly, disabled.Id: disabled},
+		roles: map[string]*domain.Role{"reader": {Name: "reader", Permissions: []domain.Permission{allow("articles", "read")}}},
+	}
+	svc := NewIdentityPolicyService(repo)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		subject string
+		code    codes.Code
+		allowed bool
+		rule    string
+	}{
+		{name: "unknown role is ignored", subject: active.Id.Hex(), code: codes.OK, allowed: true, rule: "allow:reader articles/read"},
+		{name: "only unknown roles", subject: unknownOnly.Id.Hex(), code: codes.OK, allowed: false, rule: ""},
+		{name: "inactive account", subject: disabled.Id.Hex(), code: codes.OK, allowed: false, rule: "deny:account disabled"},
+		{name: "unknown subject", subject: primitive.NewObjectID().Hex(), code: codes.NotFound},
+		{name: "malformed subject", subject: "not-an-id", code: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, code, err := svc.Authorize(ctx, tt.subject, "articles", "read")
+			if code != tt.code {
+				t.Fatalf("code %s, err %v; want %s", code, err, tt.code)
+			}
+
+			if code != codes.OK {
+				return
+			}
+
+			if resp.Allowed != tt.allowed || resp.MatchedRule != tt.rule {
+				t.Fatalf("Authorize = %v, %q; want %v, %q", resp.Allowed, resp.MatchedRule, tt.allowed, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"golang.org/x/sync/singleflight"
+)
+
+const publicKeyCacheMinRefreshInterval = 5 * time.Second
+
+type cachedPublicKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// PublicKeyCache keeps parsed verification keys (active + retiring) keyed by kid.
+// It reloads from the key provider when an unknown kid arrives, when the cached
+// set is older than maxAge, or after Invalidate is called by the key rotator.
+type PublicKeyCache struct {
+	source KeyProvider
+	maxAge time.Duration
+	group  singleflight.Group
+
+	mu       sync.RWMutex
+	keys     map[string]*cachedPublicKey
+	loadedAt time.Time
+	stale    bool
+}
+
+func NewPublicKeyCache(source KeyProvider, maxAge time.Duration) *PublicKeyCache {
+	if maxAge <= 0 {
+		maxAge = time.Minute
+	}
+
+	return &PublicKeyCache{
+		source: source,
+		maxAge: maxAge,
+		keys:   make(map[string]*cachedPublicKey),
+		stale:  true,
+	}
+}
+
+func (c *PublicKeyCache) Lookup(ctx context.Context, kid string) (*cachedPublicKey, error) {
+	key, found, fresh, canRefresh := c.get(kid)
+	if found && fresh {
+		return key, nil
+	}
+
+	if !fresh || canRefresh {
+		if err := c.refresh(ctx); err != nil {
+			if found {
+				return key, nil
+			}
+
+			return nil, err
+		}
+
+		key, found, _, _ = c.get(kid)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	return key, nil
+}
+
+func (c *PublicKeyCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stale = true
+}
+
+func (c *PublicKeyCache) get(kid string) (*cachedPublicKey, bool, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key, found := c.keys[kid]
+	age := time.Since(c.loadedAt)
+
+	fresh := !c.stale && age < c.maxAge
+	canRefresh := age >= publicKeyCacheMinRefreshInterval
+
+	return key, found, fresh, canRefresh
+}
+
+func (c *PublicKeyCache) refresh(ctx context.Context) error {
+	_, err, _ := c.group.Do("refresh", func() (any, error) {
+		authKeys, err := c.source.PublicKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make(map[string]*cachedPublicKey, len(authKeys))
+		for _, authKey := range authKeys {
+			parsed, err := parsePublicKey(authKey)
+			if err != nil {
+				return nil, fmt.Errorf("parse public key %q failed: %w", authKey.Kid, err)
+			}
+
+			keys[authKey.Kid] = parsed
+		}
+
+		c.mu.Lock()
+		c.keys = keys
+		c.loadedAt = time.Now()
+		c.stale = false
+		c.mu.Unlock()
+
+		return nil, nil
+	})
+
+	return err
+}
+
+func parsePublicKey(key *domain.AuthKey) (*cachedPublicKey, error) {
+	parsedKey, err := parseAuthPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cachedPublicKey{
+		kid: key.Kid,
+		alg: key.Alg,
+		key: parsedKey,
+	}, nil
+}
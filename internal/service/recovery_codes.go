@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	recoveryCodeCount = 10
+	// A code reads as XXXX-XXXXX-XXXXX: a 4 character lookup id followed by a 50 bit secret.
+	recoveryCodeIDLength     = 4
+	recoveryCodeSecretLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RegenerateRecoveryCodes replaces the whole set, invalidating unused old codes. The password is
+// required again so a stolen access token alone cannot mint a new break-glass factor.
+func (s *identityAuthService) RegenerateRecoveryCodes(ctx context.Context, accessToken, password string) ([]string, codes.Code, error) {
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, code, err
+	}
+
+	ok, err := s.passwords.verify(ctx, password, user.PasswordHash)
+	if err != nil {
+		return nil, passwordHashCode(err), err
+	}
+
+	if !ok {
+		return nil, codes.PermissionDenied, fmt.Errorf("password is invalid")
+	}
+
+	plain, err := s.replaceRecoveryCodes(ctx, user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.NotFound, fmt.Errorf("user not found")
+		}
+
+		return nil, passwordHashCode(err), err
+	}
+
+	return plain, codes.OK, nil
+}
+
+func (s *identityAuthService) GetRecoveryCodesStatus(ctx context.Context, accessToken string) (int, int, codes.Code, error) {
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return 0, 0, code, err
+	}
+
+	return user.RemainingRecoveryCodes(), len(user.RecoveryCodes), codes.OK, nil
+}
+
+func (s *identityAuthService) replaceRecoveryCodes(ctx context.Context, user *domain.User) ([]string, error) {
+	now := time.Now().UTC()
+
+	plain := make([]string, 0, recoveryCodeCount)
+	stored := make([]domain.RecoveryCode, 0, recoveryCodeCount)
+	seen := make(map[string]struct{}, recoveryCodeCount)
+
+	for len(plain) < recoveryCodeCount {
+		id, secret, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		hash, err := s.passwords.hash(ctx, secret)
+		if err != nil {
+			return nil, err
+		}
+
+		seen[id] = struct{}{}
+
+		plain = append(plain, formatRecoveryCode(id, secret))
+		stored = append(stored, domain.RecoveryCode{
+			ID:        id,
+			Hash:      hash,
+			CreatedAt: now,
+		})
+	}
+
+	if err := s.repo.ReplaceUserRecoveryCodes(ctx, user.Id, stored, now); err != nil {
+		return nil, err
+	}
+
+	return plain, nil
+}
+
+// useRecoveryCode spends a code as a login factor. The caller has already checked the password.
+func (s *identityAuthService) useRecoveryCode(ctx context.Context, user *domain.User, recoveryCode, userAgent, ip string) (codes.Code, error) {
+	id, secret, ok := parseRecoveryCode(recoveryCode)
+	if !ok {
+		return codes.Unauthenticated, fmt.Errorf("recovery code is invalid")
+	}
+
+	var match *domain.RecoveryCode
+	for i := range user.RecoveryCodes {
+		if user.RecoveryCodes[i].ID == id && user.RecoveryCodes[i].UsedAt == nil {
+			match = &user.RecoveryCodes[i]
+			break
+		}
+	}
+
+	if match == nil {
+		return codes.Unauthenticated, fmt.Errorf("recovery code is invalid")
+	}
+
+	matched, err := s.passwords.verify(ctx, secret, match.Hash)
+	if err != nil {
+		return passwordHashCode(err), err
+	}
+
+	if !matched {
+		return codes.Unauthenticated, fmt.Errorf("recovery code is invalid")
+	}
+
+	if err := s.repo.UseUserRecoveryCode(ctx, user.Id, id, time.Now().UTC()); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.Unauthenticated, fmt.Errorf("recovery code is invalid")
+		}
+
+		return codes.Internal, err
+	}
+
+	s.events.Emit(ctx, SecurityEvent{
+		Type:      SecurityEventRecoveryCodeUsed,
+		UserID:    user.Id.Hex(),
+		UserAgent: userAgent,
+		IPAddress: ip,
+		Details:   map[string]any{"remaining": user.RemainingRecoveryCodes() - 1},
+	})
+
+	return codes.OK, nil
+}
+
+func generateRecoveryCode() (string, string, error) {
+	raw := make([]byte, 15)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	encoded := recoveryCodeEncoding.EncodeToString(raw)
+	return encoded[:recoveryCodeIDLength], encoded[recoveryCodeIDLength : recoveryCodeIDLength+recoveryCodeSecretLength], nil
+}
+
+func formatRecoveryCode(id, secret string) string {
+	half := recoveryCodeSecretLength / 2
+	return id + "-" + secret[:half] + "-" + secret[half:]
+}
+
+func parseRecoveryCode(code string) (string, string, bool) {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	if len(normalized) != recoveryCodeIDLength+recoveryCodeSecretLength {
+		return "", "", false
+	}
+
+	return normalized[:recoveryCodeIDLength], normalized[recoveryCodeIDLength:], true
+}
+
+func recoveryAMR() []string {
+	return []string{domain.AuthMethodPassword, domain.AuthMethodMFA, domain.AuthMethodRecovery}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+)
+
+type refreshGracePayload struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresInSeconds int64  `json:"expires_in"`
+}
+
+func sealRefreshGrace(previousSecret string, resp *identity_v1.RefreshResponse) (string, error) {
+	aead, err := refreshGraceAEAD(previousSecret)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := json.Marshal(refreshGracePayload{
+		AccessToken:      resp.AccessToken,
+		RefreshToken:     resp.RefreshToken,
+		ExpiresInSeconds: resp.ExpiresInSeconds,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func openRefreshGrace(previousSecret, sealed string) (*identity_v1.RefreshResponse, error) {
+	aead, err := refreshGraceAEAD(previousSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < aead.NonceSize() {
+		return nil, fmt.Errorf("refresh grace payload invalid")
+	}
+
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("refresh grace payload invalid")
+	}
+
+	var payload refreshGracePayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, err
+	}
+
+	return &identity_v1.RefreshResponse{
+		AccessToken:      payload.AccessToken,
+		RefreshToken:     payload.RefreshToken,
+		ExpiresInSeconds: payload.ExpiresInSeconds,
+	}, nil
+}
+
+func refreshGraceAEAD(previousSecret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("refresh-grace:" + previousSecret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
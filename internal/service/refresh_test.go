@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"google.golang.org/grpc/codes"
+)
+
+type refreshEnv struct {
+	*testAuthEnv
+	sessionID string
+}
+
+func newRefreshEnv(t *testing.T) (*refreshEnv, string) {
+	t.Helper()
+
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "refresh@example.com", "correct horse battery")
+
+	token, sessionID, err := env.svc.issueRefreshSession(context.Background(), user, "test-agent", "192.0.2.1", []string{domain.AuthMethodPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &refreshEnv{testAuthEnv: env, sessionID: sessionID}, token
+}
+
+func (e *refreshEnv) refresh(token string) (*identity_v1.RefreshResponse, codes.Code, error) {
+	return e.svc.Refresh(context.Background(), &identity_v1.RefreshRequest{RefreshToken: token}, "test-agent", "192.0.2.1")
+}
+
+func (e *refreshEnv) mustRefresh(t *testing.T, token string) *identity_v1.RefreshResponse {
+	t.Helper()
+
+	resp, code, err := e.refresh(token)
+	if err != nil {
+		t.Fatalf("refresh: %s, %v", code, err)
+	}
+
+	return resp
+}
+
+func (e *refreshEnv) session() domain.RefreshSession {
+	e.repo.mu.Lock()
+	defer e.repo.mu.Unlock()
+
+	return *e.repo.sessions[e.sessionID]
+}
+
+// assertRevokedForReuse checks the whole response to reuse: the session is revoked, its sid is
+// denied for access tokens and the event is emitted.
+func (e *refreshEnv) assertRevokedForReuse(t *testing.T) {
+	t.Helper()
+
+	session := e.session()
+	if session.RevokedAt == nil || session.RevokedReason != domain.SessionRevokeReasonRefreshTokenReuse {
+		t.Fatalf("session revoked at %v for %q, want revocation for reuse", session.RevokedAt, session.RevokedReason)
+	}
+
+	denied, err := e.repo.IsTokenRevoked(context.Background(), "", e.sessionID)
+	if err != nil || !denied {
+		t.Fatalf("sid denied = %v, %v; want the session on the denylist", denied, err)
+	}
+
+	if !slices.Contains(e.events.types(), string(SecurityEventRefreshTokenReuse)) {
+		t.Fatalf("events %v, want %s", e.events.types(), SecurityEventRefreshTokenReuse)
+	}
+}
+
+func TestRefreshRotatesTheToken(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	resp := env.mustRefresh(t, first)
+	if resp.RefreshToken == first || resp.AccessToken == "" {
+		t.Fatalf("refresh did not rotate: %+v", resp)
+	}
+
+	if session := env.session(); session.Generation != 1 || session.RefreshTokenHash == "" || !slices.Equal(session.PreviousTokenHashes, []string{hashOfRefreshToken(t, first)}) {
+		t.Fatalf("session after rotation: generation %d, previous %v", session.Generation, session.PreviousTokenHashes)
+	}
+
+	env.mustRefresh(t, resp.RefreshToken)
+}
+
+func TestRefreshWithAnOlderTokenRevokesTheSession(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	second := env.mustRefresh(t, first).RefreshToken
+	third := env.mustRefresh(t, second).RefreshToken
+
+	// first is two rotations back, so no grace window covers it.
+	if _, code, err := env.refresh(first); code != codes.Unauthenticated || err == nil {
+		t.Fatalf("reused token: %s, %v; want %s", code, err, codes.Unauthenticated)
+	}
+
+	env.assertRevokedForReuse(t)
+
+	if _, code, _ := env.refresh(third); code != codes.Unauthenticated {
+		t.Fatalf("current token after reuse: %s, want %s", code, codes.Unauthenticated)
+	}
+}
+
+func TestRefreshWithAForeignSecretIsNotReuse(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	forged, _, err := buildRefreshToken(env.sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, code, err := env.refresh(forged); code != codes.Unauthenticated || err == nil {
+		t.Fatalf("forged token: %s, %v; want %s", code, err, codes.Unauthenticated)
+	}
+
+	if session := env.session(); session.RevokedAt != nil {
+		t.Fatalf("a token the session never issued revoked it")
+	}
+
+	env.mustRefresh(t, first)
+}
+
+func TestRefreshReplaysThePairInsideTheGraceWindow(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	issued := env.mustRefresh(t, first)
+	replayed := env.mustRefresh(t, first)
+
+	if replayed.AccessToken != issued.AccessToken || replayed.RefreshToken != issued.RefreshToken {
+		t.Fatalf("grace replay returned a different pair")
+	}
+
+	if session := env.session(); session.RevokedAt != nil || session.Generation != 1 {
+		t.Fatalf("grace replay changed the session: revoked %v, generation %d", session.RevokedAt, session.Generation)
+	}
+}
+
+func TestRefreshAfterTheGraceWindowIsReuse(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	env.mustRefresh(t, first)
+	env.expireGrace()
+
+	if _, code, err := env.refresh(first); code != codes.Unauthenticated || err == nil {
+		t.Fatalf("previous token after the window: %s, %v; want %s", code, err, codes.Unauthenticated)
+	}
+
+	env.assertRevokedForReuse(t)
+}
+
+func TestRefreshLosingTheRotationInsideTheGraceWindow(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	var winner *identity_v1.RefreshResponse
+	env.repo.beforeRotate = func() { winner = env.mustRefresh(t, first) }
+
+	resp, code, err := env.refresh(first)
+	if err != nil {
+		t.Fatalf("losing refresh: %s, %v", code, err)
+	}
+
+	if resp.AccessToken != winner.AccessToken || resp.RefreshToken != winner.RefreshToken {
+		t.Fatalf("losing refresh did not get the winner's sealed pair")
+	}
+
+	if session := env.session(); session.RevokedAt != nil || session.Generation != 1 {
+		t.Fatalf("losing refresh changed the session: revoked %v, generation %d", session.RevokedAt, session.Generation)
+	}
+}
+
+func TestRefreshLosingTheRotationOutsideTheGraceWindowIsReuse(t *testing.T) {
+	env, first := newRefreshEnv(t)
+
+	env.repo.beforeRotate = func() {
+		env.mustRefresh(t, first)
+		env.expireGrace()
+	}
+
+	if _, code, err := env.refresh(first); code != codes.Unauthenticated || err == nil {
+		t.Fatalf("losing refresh outside the window: %s, %v; want %s", code, err, codes.Unauthenticated)
+	}
+
+	env.assertRevokedForReuse(t)
+}
+
+// expireGrace moves the session's grace window into the past.
+func (e *refreshEnv) expireGrace() {
+	e.repo.mu.Lock()
+	defer e.repo.mu.Unlock()
+
+	if grace := e.repo.sessions[e.sessionID].Grace; grace != nil {
+		grace.Until = time.Now().Add(-time.Second)
+	}
+}
+
+func hashOfRefreshToken(t *testing.T, token string) string {
+	t.Helper()
+
+	_, secret, err := parseRefreshToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hashRefreshToken(secret)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// secretBox encrypts small secrets (MFA seeds) at rest with AES-GCM. The associated data binds
+// a ciphertext to its owner so it cannot be copied onto another user document.
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+func newSecretBox(key []byte) (*secretBox, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretBox{aead: aead}, nil
+}
+
+func (b *secretBox) seal(plaintext, associatedData string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(associatedData))
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *secretBox) open(sealed, associatedData string) (string, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	if len(raw) < b.aead.NonceSize() {
+		return "", fmt.Errorf("sealed secret invalid")
+	}
+
+	plaintext, err := b.aead.Open(nil, raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():], []byte(associatedData))
+	if err != nil {
+		return "", fmt.Errorf("sealed secret invalid")
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type SecurityEventType string
+
+const (
+	SecurityEventRefreshTokenReuse SecurityEventType = "refresh_token_reuse"
+	SecurityEventRecoveryCodeUsed  SecurityEventType = "recovery_code_used"
+	SecurityEventWebAuthnClone     SecurityEventType = "webauthn_clone_detected"
+)
+
+type SecurityEvent struct {
+	Type      SecurityEventType
+	UserID    string
+	SessionID string
+	UserAgent string
+	IPAddress string
+	Details   map[string]any
+}
+
+// SecurityEventEmitter receives security-relevant events (token reuse, lockouts, ...).
+type SecurityEventEmitter interface {
+	Emit(ctx context.Context, event SecurityEvent)
+}
+
+type logSecurityEventEmitter struct {
+	logger *logrus.Entry
+}
+
+func NewLogSecurityEventEmitter(logger *logrus.Entry) SecurityEventEmitter {
+	if logger == nil {
+		logger = logrus.WithField("scope", "security")
+	}
+
+	return &logSecurityEventEmitter{logger: logger}
+}
+
+func (e *logSecurityEventEmitter) Emit(ctx context.Context, event SecurityEvent) {
+	fields := logrus.Fields{
+		"event":      event.Type,
+		"user_id":    event.UserID,
+		"session_id": event.SessionID,
+		"user_agent": event.UserAgent,
+		"ip_address": event.IPAddress,
+	}
+
+	for k, v := range event.Details {
+		fields[k] = v
+	}
+
+	e.logger.WithContext(ctx).WithFields(fields).Warn("security event")
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+func (s *identityAuthService) ListSessions(ctx context.Context, accessToken string) ([]*identity_v1.Session, codes.Code, error) {
+	user, claims, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return nil, code, err
+	}
+
+	sessions, err := s.repo.ListActiveUserRefreshSessions(ctx, user.Id, time.Now().UTC())
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	return sessionsToProto(sessions, claims.SessionID), codes.OK, nil
+}
+
+func (s *identityAuthService) RevokeSession(ctx context.Context, accessToken, sessionID string) (codes.Code, error) {
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return code, err
+	}
+
+	now := time.Now().UTC()
+
+	if err := s.repo.RevokeUserRefreshSession(ctx, user.Id, sessionID, now, domain.SessionRevokeReasonUser); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return codes.NotFound, fmt.Errorf("session not found")
+		}
+
+		return codes.Internal, err
+	}
+
+	if err := denySessions(ctx, s.repo, []string{sessionID}, domain.SessionRevokeReasonUser, now, s.accessTTL); err != nil {
+		return codes.Internal, err
+	}
+
+	return codes.OK, nil
+}
+
+// RevokeOtherSessions keeps the caller's session and revokes the rest. The current session is taken
+// from the access token's sid claim, or from refreshToken for tokens issued without one.
+func (s *identityAuthService) RevokeOtherSessions(ctx context.Context, accessToken, refreshToken string) (int64, codes.Code, error) {
+	user, claims, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return 0, code, err
+	}
+
+	currentSessionID := claims.SessionID
+
+	if strings.TrimSpace(refreshToken) != "" {
+		sessionID, tokenHash, err := splitRefreshToken(strings.TrimSpace(refreshToken))
+		if err != nil {
+			return 0, codes.InvalidArgument, err
+		}
+
+		current, err := s.repo.FindRefreshSession(ctx, sessionID)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return 0, codes.NotFound, fmt.Errorf("session not found")
+			}
+
+			return 0, codes.Internal, err
+		}
+
+		if current.UserID != user.Id || current.RevokedAt != nil || current.RefreshTokenHash != tokenHash {
+			return 0, codes.PermissionDenied, fmt.Errorf("refresh token does not belong to an active session of the caller")
+		}
+
+		currentSessionID = current.SessionID
+	}
+
+	if currentSessionID == "" {
+		return 0, codes.InvalidArgument, fmt.Errorf("current session is unknown, refresh token is required")
+	}
+
+	count, err := revokeUserSessions(ctx, s.repo, user.Id, currentSessionID, domain.SessionRevokeReasonUser, s.accessTTL)
+	if err != nil {
+		return 0, codes.Internal, err
+	}
+
+	return count, codes.OK, nil
+}
+
+func sessionsToProto(sessions []*domain.RefreshSession, currentSessionID string) []*identity_v1.Session {
+	result := make([]*identity_v1.Session, 0, len(sessions))
+
+	for _, session := range sessions {
+		result = append(result, &identity_v1.Session{
+			SessionId: session.SessionID,
+			UserAgent: session.UserAgent,
+			IpAddress: session.IPAddress,
+			CreatedAt: session.CreatedAt.Unix(),
+			UpdatedAt: session.UpdatedAt.Unix(),
+			ExpiresAt: session.ExpiresAt.Unix(),
+			Current:   currentSessionID != "" && session.SessionID == currentSessionID,
+			Recovery:  session.Recovery,
+		})
+	}
+
+	return result
+}
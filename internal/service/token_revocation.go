@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type sessionRevoker interface {
+	RevokeUserRefreshSessions(context.Context, primitive.ObjectID, string, time.Time, string) ([]string, error)
+	InsertRevokedTokens(context.Context, []*domain.RevokedToken) error
+}
+
+// denySessions puts the sids on the access token denylist for accessTTL, i.e. until every
+// access token issued for those sessions has expired anyway.
+func denySessions(ctx context.Context, repo sessionRevoker, sessionIDs []string, reason string, revokedAt time.Time, accessTTL time.Duration) error {
+	tokens := make([]*domain.RevokedToken, 0, len(sessionIDs))
+
+	for _, sessionID := range sessionIDs {
+		tokens = append(tokens, &domain.RevokedToken{
+			Kind:      domain.RevokedTokenKindSID,
+			Value:     sessionID,
+			Reason:    reason,
+			RevokedAt: revokedAt,
+			ExpiresAt: revokedAt.Add(accessTTL),
+		})
+	}
+
+	return repo.InsertRevokedTokens(ctx, tokens)
+}
+
+// revokeUserSessions revokes the refresh sessions of a user and denies their access tokens.
+func revokeUserSessions(ctx context.Context, repo sessionRevoker, userID primitive.ObjectID, exceptSessionID, reason string, accessTTL time.Duration) (int64, error) {
+	now := time.Now().UTC()
+
+	sessionIDs, err := repo.RevokeUserRefreshSessions(ctx, userID, exceptSessionID, now, reason)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := denySessions(ctx, repo, sessionIDs, reason, now, accessTTL); err != nil {
+		return 0, err
+	}
+
+	return int64(len(sessionIDs)), nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/invenlore/identity.service/internal/domain"
+	"google.golang.org/grpc/codes"
+)
+
+func TestValidateTokenConsultsTheDenylist(t *testing.T) {
+	tests := []struct {
+		name string
+		kind domain.RevokedTokenKind
+		// value picks the denied value out of the token's claims.
+		value func(*accessTokenClaims) string
+	}{
+		{name: "jti", kind: domain.RevokedTokenKindJTI, value: func(c *accessTokenClaims) string { return c.ID }},
+		{name: "sid", kind: domain.RevokedTokenKindSID, value: func(c *accessTokenClaims) string { return c.SessionID }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestAuthEnv(t, testAuthSettings())
+			ctx := context.Background()
+			user := env.addUser(t, "denied@example.com", "correct horse battery")
+
+			denied := env.accessToken(t, user)
+			other := env.accessToken(t, user)
+
+			claims := &accessTokenClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(denied, claims); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, code, err := env.svc.ValidateToken(ctx, denied); err != nil {
+				t.Fatalf("before revocation: %s, %v", code, err)
+			}
+
+			now := time.Now().UTC()
+			if err := env.repo.InsertRevokedTokens(ctx, []*domain.RevokedToken{{
+				Kind:      tt.kind,
+				Value:     tt.value(claims),
+				RevokedAt: now,
+				ExpiresAt: now.Add(time.Minute),
+			}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, code, err := env.svc.ValidateToken(ctx, denied); code != codes.Unauthenticated || err == nil {
+				t.Fatalf("after revocation: %s, %v; want %s", code, err, codes.Unauthenticated)
+			}
+
+			// A jti entry denies one token; a sid entry denies every token of the session.
+			_, code, err := env.svc.ValidateToken(ctx, other)
+			if tt.kind == domain.RevokedTokenKindJTI && err != nil {
+				t.Fatalf("sibling token after a jti revocation: %s, %v", code, err)
+			}
+
+			if tt.kind == domain.RevokedTokenKindSID && code != codes.Unauthenticated {
+				t.Fatalf("sibling token after a sid revocation: %s, want %s", code, codes.Unauthenticated)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B.
+var rfc6238Secret = []byte("12345678901234567890")
+
+// TestTOTPCodeMatchesRFC6238 uses the SHA-1 vectors of RFC 6238 Appendix B. The RFC lists 8 digit
+// codes; a 6 digit code is the same value modulo 10^6, i.e. its last six digits.
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+		{unix: 20000000000, code: "353130"},
+	}
+
+	for _, tt := range tests {
+		if got := totpCode(rfc6238Secret, totpStep(time.Unix(tt.unix, 0))); got != tt.code {
+			t.Errorf("T=%d: got %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	encoded := totpEncoding.EncodeToString(rfc6238Secret)
+	now := time.Unix(1111111111, 0)
+	current := totpStep(now)
+	codeAt := func(step int64) string { return totpCode(rfc6238Secret, step) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{name: "current step", secret: encoded, code: codeAt(current), step: current, ok: true},
+		{name: "one step behind", secret: encoded, code: codeAt(current - 1), step: current - 1, ok: true},
+		{name: "one step ahead", secret: encoded, code: codeAt(current + 1), step: current + 1, ok: true},
+		{name: "two steps behind", secret: encoded, code: codeAt(current - 2)},
+		{name: "two steps ahead", secret: encoded, code: codeAt(current + 2)},
+		{name: "surrounding spaces", secret: encoded, code: " " + codeAt(current) + " ", step: current, ok: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: codeAt(current), step: current, ok: true},
+		{name: "replay of the last used step", secret: encoded, code: codeAt(current), lastStep: current},
+		{name: "step before the last used one", secret: encoded, code: codeAt(current - 1), lastStep: current},
+		{name: "step after the last used one", secret: encoded, code: codeAt(current + 1), lastStep: current, step: current + 1, ok: true},
+		{name: "current step after an older use", secret: encoded, code: codeAt(current), lastStep: current - 1, step: current, ok: true},
+		{name: "wrong code", secret: encoded, code: "000000"},
+		{name: "too short", secret: encoded, code: codeAt(current)[:5]},
+		{name: "too long", secret: encoded, code: codeAt(current) + "0"},
+		{name: "undecodable secret", secret: "!!!", code: codeAt(current)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := verifyTOTP(tt.secret, tt.code, now, tt.lastStep)
+			if ok != tt.ok || step != tt.step {
+				t.Fatalf("verifyTOTP = %d, %v; want %d, %v", step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
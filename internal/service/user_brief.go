@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/invenlore/identity.service/internal/repository"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	userBriefCacheSize = 10000
+	userBriefCacheTTL  = 30 * time.Second
+)
+
+type IdentityUserBriefService interface {
+	GetUserBriefs(ctx context.Context, ids []string) ([]*identity_v1.UserBrief, []string, codes.Code, error)
+	Forget(id string)
+}
+
+type identityUserBriefService struct {
+	repo  repository.IdentityAdminRepository
+	cache *userBriefCache
+}
+
+func NewIdentityUserBriefService(repo repository.IdentityAdminRepository) IdentityUserBriefService {
+	return &identityUserBriefService{
+		repo:  repo,
+		cache: newUserBriefCache(userBriefCacheSize, userBriefCacheTTL),
+	}
+}
+
+// GetUserBriefs resolves ids in request order; ids that are malformed or have
+// no user are reported back as not found instead of failing the whole batch.
+// Ids are matched in their canonical lowercase hex form, so "ABC…" and "abc…" are one user.
+func (s *identityUserBriefService) GetUserBriefs(ctx context.Context, ids []string) ([]*identity_v1.UserBrief, []string, codes.Code, error) {
+	var (
+		found   = make(map[string]*identity_v1.UserBrief, len(ids))
+		missing = make([]primitive.ObjectID, 0, len(ids))
+		seen    = make(map[string]struct{}, len(ids))
+		ordered = make([]string, 0, len(ids))
+		keys    = make(map[string]string, len(ids))
+	)
+
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		ordered = append(ordered, id)
+
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			continue
+		}
+
+		key := objID.Hex()
+		keys[id] = key
+
+		if _, ok := found[key]; ok {
+			continue
+		}
+
+		if brief, ok := s.cache.Get(key); ok {
+			found[key] = brief
+			continue
+		}
+
+		// A placeholder keeps differently cased duplicates from being queried twice.
+		found[key] = nil
+		missing = append(missing, objID)
+	}
+
+	if len(missing) > 0 {
+		users, err := s.repo.FindUsersByIDs(ctx, missing)
+		if err != nil {
+			return nil, nil, codes.Internal, err
+		}
+
+		for _, u := range users {
+			brief := &identity_v1.UserBrief{
+				Id:   u.Id.Hex(),
+				Name: u.Name,
+			}
+
+			s.cache.Put(brief.Id, brief)
+			found[brief.Id] = brief
+		}
+	}
+
+	briefs := make([]*identity_v1.UserBrief, 0, len(found))
+	notFound := make([]string, 0)
+	returned := make(map[string]struct{}, len(found))
+
+	for _, id := range ordered {
+		key, ok := keys[id]
+		brief := found[key]
+
+		if !ok || brief == nil {
+			notFound = append(notFound, id)
+			continue
+		}
+
+		if _, dup := returned[key]; dup {
+			continue
+		}
+
+		returned[key] = struct{}{}
+		briefs = append(briefs, brief)
+	}
+
+	return briefs, notFound, codes.OK, nil
+}
+
+func (s *identityUserBriefService) Forget(id string) {
+	if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+		id = objID.Hex()
+	}
+
+	s.cache.Remove(id)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+)
+
+type userBriefCacheEntry struct {
+	id        string
+	brief     *identity_v1.UserBrief
+	expiresAt time.Time
+}
+
+// userBriefCache is a fixed-size LRU of user briefs keyed by user ID; entries
+// expire after ttl, so renames and deletions become visible within that window.
+type userBriefCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newUserBriefCache(size int, ttl time.Duration) *userBriefCache {
+	return &userBriefCache{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *userBriefCache) Get(id string) (*identity_v1.UserBrief, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*userBriefCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.items, id)
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return entry.brief, true
+}
+
+func (c *userBriefCache) Put(id string, brief *identity_v1.UserBrief) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+
+	if elem, ok := c.items[id]; ok {
+		entry := elem.Value.(*userBriefCacheEntry)
+		entry.brief = brief
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.items[id] = c.order.PushFront(&userBriefCacheEntry{id: id, brief: brief, expiresAt: expiresAt})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*userBriefCacheEntry).id)
+	}
+}
+
+func (c *userBriefCache) Remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		c.order.Remove(elem)
+		delete(c.items, id)
+	}
+}
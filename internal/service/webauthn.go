@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/invenlore/identity.service/internal/domain"
+	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+)
+
+const webauthnChallengeSize = 32
+
+type webauthnCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type webauthnCreationOptions struct {
+	Challenge string `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams       []webauthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	Attestation            string                         `json:"attestation"`
+	ExcludeCredentials     []webauthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+}
+
+type webauthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type webauthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	UserVerification string                         `json:"userVerification"`
+	AllowCredentials []webauthnCredentialDescriptor `json:"allowCredentials"`
+}
+
+// BeginWebAuthnRegistration returns PublicKeyCredentialCreationOptions as JSON, ready to be passed to
+// navigator.credentials.create after base64url decoding the binary fields.
+func (s *identityAuthService) BeginWebAuthnRegistration(ctx context.Context, accessToken string) (string, codes.Code, error) {
+	if s.webauthnRPID == "" {
+		return "", codes.FailedPrecondition, fmt.Errorf("webauthn is not configured")
+	}
+
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return "", code, err
+	}
+
+	existing, err := s.repo.ListUserWebAuthnCredentials(ctx, user.Id)
+	if err != nil {
+		return "", codes.Internal, err
+	}
+
+	challenge, err := s.issueWebAuthnChallenge(ctx, domain.ActionTokenPurposeWebAuthnRegister, user.Id, "")
+	if err != nil {
+		return "", codes.Internal, err
+	}
+
+	options := webauthnCreationOptions{
+		Challenge:          challenge,
+		PubKeyCredParams:   []webauthnCredentialParameter{{Type: "public-key", Alg: coseAlgES256}},
+		Timeout:            s.webauthnChallengeTTL.Milliseconds(),
+		Attestation:        "direct",
+		ExcludeCredentials: credentialDescriptors(existing),
+	}
+	options.RP.ID = s.webauthnRPID
+	options.RP.Name = s.webauthnRPName
+	options.User.ID = base64.RawURLEncoding.EncodeToString(user.Id[:])
+	options.User.Name = user.Email
+	options.User.DisplayName = user.Name
+	// Login never lists credential ids, so only discoverable credentials can be used to sign in.
+	options.AuthenticatorSelection.ResidentKey = "required"
+	options.AuthenticatorSelection.UserVerification = "preferred"
+
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return "", codes.Internal, err
+	}
+
+	return string(raw), codes.OK, nil
+}
+
+func (s *identityAuthService) FinishWebAuthnRegistration(ctx context.Context, accessToken, name string, clientDataJSON, attestationObject []byte, transports []string) (string, codes.Code, error) {
+	if s.webauthnRPID == "" {
+		return "", codes.FailedPrecondition, fmt.Errorf("webauthn is not configured")
+	}
+
+	user, _, code, err := s.authenticate(ctx, accessToken)
+	if err != nil {
+		return "", code, err
+	}
+
+	clientData, err := parseClientData(clientDataJSON, webauthnTypeCreate, s.webauthnOrigins)
+	if err != nil {
+		return "", codes.InvalidArgument, err
+	}
+
+	now := time.Now().UTC()
+
+	challenge, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposeWebAuthnRegister, hashRefreshToken(clientData.Challenge), now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", codes.InvalidArgument, fmt.Errorf("registration challenge is invalid or expired")
+		}
+
+		return "", codes.Internal, err
+	}
+
+	if challenge.UserID != user.Id {
+		return "", codes.InvalidArgument, fmt.Errorf("registration challenge is invalid or expired")
+	}
+
+	var obj webauthnAttestationObject
+	if err := cbor.Unmarshal(attestationObject, &obj); err != nil {
+		return "", codes.InvalidArgument, fmt.Errorf("attestation object invalid: %w", err)
+	}
+
+	authData, err := parseAuthenticatorData(obj.AuthData, s.webauthnRPID)
+	if err != nil {
+		return "", codes.InvalidArgument, err
+	}
+
+	if authData.credentialID == nil {
+		return "", codes.InvalidArgument, fmt.Errorf("attested credential data is missing")
+	}
+
+	publicKey, alg, err := parseCOSEPublicKey(authData.publicKey)
+	if err != nil {
+		return "", codes.InvalidArgument, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestation(&obj, authData, publicKey, alg, clientDataHash[:]); err != nil {
+		return "", codes.InvalidArgument, err
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(authData.credentialID)
+
+	if err := s.repo.InsertWebAuthnCredential(ctx, &domain.WebAuthnCredential{
+		UserID:            user.Id,
+		CredentialID:      credentialID,
+		PublicKey:         authData.publicKey,
+		Algorithm:         alg,
+		AttestationFormat: obj.Format,
+		AAGUID:            formatAAGUID(authData.aaguid),
+		SignCount:         int64(authData.signCount),
+		Transports:        transports,
+		Name:              strings.TrimSpace(name),
+		CreatedAt:         now,
+	}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", codes.AlreadyExists, fmt.Errorf("credential is already registered")
+		}
+
+		return "", codes.Internal, err
+	}
+
+	return credentialID, codes.OK, nil
+}
+
+// BeginWebAuthnLogin issues an unbound assertion challenge for a discoverable-credential (passkey)
+// login. It takes no email: listing a user's credential ids would tell anyone which addresses are
+// registered. The endpoint is unauthenticated and stores a challenge per call, so it is rate
+// limited per client IP.
+func (s *identityAuthService) BeginWebAuthnLogin(ctx context.Context, ip string) (string, codes.Code, error) {
+	if s.webauthnRPID == "" {
+		return "", codes.FailedPrecondition, fmt.Errorf("webauthn is not configured")
+	}
+
+	if !s.webauthnLoginLimiter.Allow(ip, time.Now()) {
+		return "", codes.ResourceExhausted, fmt.Errorf("too many login challenges, retry later")
+	}
+
+	challenge, err := s.issueWebAuthnChallenge(ctx, domain.ActionTokenPurposeWebAuthnLogin, primitive.NilObjectID, ip)
+	if err != nil {
+		return "", codes.Internal, err
+	}
+
+	raw, err := json.Marshal(webauthnRequestOptions{
+		Challenge:        challenge,
+		RPID:             s.webauthnRPID,
+		Timeout:          s.webauthnChallengeTTL.Milliseconds(),
+		UserVerification: "preferred",
+		AllowCredentials: []webauthnCredentialDescriptor{},
+	})
+	if err != nil {
+		return "", codes.Internal, err
+	}
+
+	return string(raw), codes.OK, nil
+}
+
+// FinishWebAuthnLogin verifies an assertion and opens a session like Login. A sign count that does
+// not move forward means the key material exists in two places; the credential is then refused.
+// Users with TOTP enabled have asked for two factors, so for them a presence-only assertion is not
+// enough: the authenticator must have verified the user (PIN or biometric) as well.
+func (s *identityAuthService) FinishWebAuthnLogin(ctx context.Context, credentialID string, clientDataJSON, authenticatorData, signature, userHandle []byte, userAgent, ip string) (*identity_v1.LoginResponse, codes.Code, error) {
+	if s.webauthnRPID == "" {
+		return nil, codes.FailedPrecondition, fmt.Errorf("webauthn is not configured")
+	}
+
+	clientData, err := parseClientData(clientDataJSON, webauthnTypeGet, s.webauthnOrigins)
+	if err != nil {
+		return nil, codes.InvalidArgument, err
+	}
+
+	now := time.Now().UTC()
+
+	challenge, err := s.repo.ConsumeActionToken(ctx, domain.ActionTokenPurposeWebAuthnLogin, hashRefreshToken(clientData.Challenge), now)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("login challenge is invalid or expired")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	credential, err := s.repo.FindWebAuthnCredential(ctx, strings.TrimSpace(credentialID))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("credential is not registered")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	if !challenge.UserID.IsZero() && challenge.UserID != credential.UserID {
+		return nil, codes.Unauthenticated, fmt.Errorf("credential does not belong to the challenged user")
+	}
+
+	if len(userHandle) > 0 && string(userHandle) != string(credential.UserID[:]) {
+		return nil, codes.Unauthenticated, fmt.Errorf("user handle does not match credential")
+	}
+
+	if credential.CloneDetectedAt != nil {
+		return nil, codes.PermissionDenied, fmt.Errorf("credential is disabled after a suspected clone")
+	}
+
+	authData, err := parseAuthenticatorData(authenticatorData, s.webauthnRPID)
+	if err != nil {
+		return nil, codes.Unauthenticated, err
+	}
+
+	publicKey, _, err := parseCOSEPublicKey(credential.PublicKey)
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if !verifyES256(publicKey, append(append([]byte{}, authenticatorData...), clientDataHash[:]...), signature) {
+		return nil, codes.Unauthenticated, fmt.Errorf("assertion signature invalid")
+	}
+
+	signCount := int64(authData.signCount)
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		if err := s.repo.MarkWebAuthnCredentialCloned(ctx, credential.Id, now); err != nil {
+			return nil, codes.Internal, err
+		}
+
+		s.events.Emit(ctx, SecurityEvent{
+			Type:      SecurityEventWebAuthnClone,
+			UserID:    credential.UserID.Hex(),
+			UserAgent: userAgent,
+			IPAddress: ip,
+			Details: map[string]any{
+				"credential_id":     credential.CredentialID,
+				"stored_sign_count": credential.SignCount,
+				"sign_count":        signCount,
+			},
+		})
+
+		return nil, codes.PermissionDenied, fmt.Errorf("credential is disabled after a suspected clone")
+	}
+
+	if err := s.repo.UpdateWebAuthnSignCount(ctx, credential.Id, credential.SignCount, signCount, now); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Aborted, fmt.Errorf("credential was used concurrently")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	user, err := s.repo.FindUserByID(ctx, credential.UserID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, codes.Unauthenticated, fmt.Errorf("credential is not registered")
+		}
+
+		return nil, codes.Internal, err
+	}
+
+	if err := checkAccountStatus(user); err != nil {
+		return nil, codes.PermissionDenied, err
+	}
+
+	if err := s.checkEmailVerified(user); err != nil {
+		return nil, codes.FailedPrecondition, err
+	}
+
+	if user.TOTPEnabled() && !authData.userVerified() {
+		return nil, codes.Unauthenticated, fmt.Errorf("user verification is required for this account")
+	}
+
+	amr := []string{domain.AuthMethodHardware, domain.AuthMethodPresence}
+	if authData.userVerified() {
+		amr = append(amr, domain.AuthMethodMFA)
+	}
+
+	resp, err := s.completeLogin(ctx, user, userAgent, ip, amr)
+	if err != nil {
+		return nil, codes.Internal, err
+	}
+
+	return resp, codes.OK, nil
+}
+
+// issueWebAuthnChallenge stores the challenge like other action tokens. Unlike issueActionToken it
+// does not clear earlier challenges, since unbound login challenges all share the nil user id.
+func (s *identityAuthService) issueWebAuthnChallenge(ctx context.Context, purpose domain.ActionTokenPurpose, userID primitive.ObjectID, ip string) (string, error) {
+	raw := make([]byte, webauthnChallengeSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	challenge := base64.RawURLEncoding.EncodeToString(raw)
+	now := time.Now().UTC()
+
+	if err := s.repo.InsertActionToken(ctx, &domain.ActionToken{
+		Purpose:   purpose,
+		TokenHash: hashRefreshToken(challenge),
+		UserID:    userID,
+		IPAddress: ip,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.webauthnChallengeTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+func credentialDescriptors(credentials []*domain.WebAuthnCredential) []webauthnCredentialDescriptor {
+	result := make([]webauthnCredentialDescriptor, 0, len(credentials))
+
+	for _, credential := range credentials {
+		if credential.CloneDetectedAt != nil {
+			continue
+		}
+
+		result = append(result, webauthnCredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.CredentialID,
+			Transports: credential.Transports,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Only the subset of WebAuthn Level 2 needed for passkeys is implemented: ES256 credentials,
+// "none" and "packed" attestation. Packed certificate chains are checked for shape and signature
+// but not against a metadata service, so attestation proves key possession, not authenticator model.
+const (
+	coseAlgES256    = -7
+	coseKeyTypeEC2  = 2
+	coseCurveP256   = 1
+	es256CoordBytes = 32
+
+	authDataMinLength              = 37
+	authDataFlagUserPresent        = 0x01
+	authDataFlagUserVerified       = 0x04
+	authDataFlagAttestedCredential = 0x40
+
+	webauthnTypeCreate = "webauthn.create"
+	webauthnTypeGet    = "webauthn.get"
+
+	attestationFormatNone   = "none"
+	attestationFormatPacked = "packed"
+)
+
+// id-fido-gen-ce-aaguid, carried by packed attestation certificates.
+var oidFIDOAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+type webauthnClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+type webauthnAuthData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (d *webauthnAuthData) userPresent() bool {
+	return d.flags&authDataFlagUserPresent != 0
+}
+
+func (d *webauthnAuthData) userVerified() bool {
+	return d.flags&authDataFlagUserVerified != 0
+}
+
+type webauthnAttestationObject struct {
+	Format   string          `cbor:"fmt"`
+	AttStmt  cbor.RawMessage `cbor:"attStmt"`
+	AuthData []byte          `cbor:"authData"`
+}
+
+type packedAttestationStatement struct {
+	Alg int64    `cbor:"alg"`
+	Sig []byte   `cbor:"sig"`
+	X5C [][]byte `cbor:"x5c,omitempty"`
+}
+
+type coseEC2Key struct {
+	KeyType   int64  `cbor:"1,keyasint"`
+	Algorithm int64  `cbor:"3,keyasint"`
+	Curve     int64  `cbor:"-1,keyasint"`
+	X         []byte `cbor:"-2,keyasint"`
+	Y         []byte `cbor:"-3,keyasint"`
+}
+
+// parseClientData checks the ceremony type and origin and returns the parsed client data.
+func parseClientData(raw []byte, expectedType string, origins []string) (*webauthnClientData, error) {
+	var clientData webauthnClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return nil, fmt.Errorf("client data invalid: %w", err)
+	}
+
+	if clientData.Type != expectedType {
+		return nil, fmt.Errorf("client data type %q, expected %q", clientData.Type, expectedType)
+	}
+
+	if clientData.CrossOrigin {
+		return nil, fmt.Errorf("cross-origin ceremonies are not allowed")
+	}
+
+	if !slices.Contains(origins, clientData.Origin) {
+		return nil, fmt.Errorf("origin %q is not allowed", clientData.Origin)
+	}
+
+	if clientData.Challenge == "" {
+		return nil, fmt.Errorf("client data challenge is missing")
+	}
+
+	return &clientData, nil
+}
+
+func parseAuthenticatorData(raw []byte, rpID string) (*webauthnAuthData, error) {
+	if len(raw) < authDataMinLength {
+		return nil, fmt.Errorf("authenticator data too short")
+	}
+
+	data := &webauthnAuthData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+
+	expectedRPIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(data.rpIDHash, expectedRPIDHash[:]) {
+		return nil, fmt.Errorf("authenticator data rp id hash mismatch")
+	}
+
+	if !data.userPresent() {
+		return nil, fmt.Errorf("user presence is required")
+	}
+
+	if data.flags&authDataFlagAttestedCredential == 0 {
+		return data, nil
+	}
+
+	rest := raw[authDataMinLength:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("attested credential data too short")
+	}
+
+	data.aaguid = rest[:16]
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+
+	if idLength == 0 || len(rest) < idLength {
+		return nil, fmt.Errorf("credential id length invalid")
+	}
+
+	data.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	// The COSE key is followed by optional extension data, so its length is only known by decoding it.
+	var key cbor.RawMessage
+	remaining, err := cbor.UnmarshalFirst(rest, &key)
+	if err != nil {
+		return nil, fmt.Errorf("credential public key invalid: %w", err)
+	}
+
+	data.publicKey = rest[:len(rest)-len(remaining)]
+
+	return data, nil
+}
+
+func parseCOSEPublicKey(raw []byte) (*ecdsa.PublicKey, int64, error) {
+	var key coseEC2Key
+	if err := cbor.Unmarshal(raw, &key); err != nil {
+		return nil, 0, fmt.Errorf("cose key invalid: %w", err)
+	}
+
+	if key.KeyType != coseKeyTypeEC2 || key.Algorithm != coseAlgES256 || key.Curve != coseCurveP256 {
+		return nil, 0, fmt.Errorf("unsupported credential key (kty %d, alg %d, crv %d), only ES256 is accepted", key.KeyType, key.Algorithm, key.Curve)
+	}
+
+	if len(key.X) != es256CoordBytes || len(key.Y) != es256CoordBytes {
+		return nil, 0, fmt.Errorf("cose key coordinates invalid")
+	}
+
+	point := append([]byte{0x04}, append(append([]byte{}, key.X...), key.Y...)...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, 0, fmt.Errorf("cose key is not on P-256: %w", err)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(key.X),
+		Y:     new(big.Int).SetBytes(key.Y),
+	}, key.Algorithm, nil
+}
+
+func verifyES256(key *ecdsa.PublicKey, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	return ecdsa.VerifyASN1(key, digest[:], signature)
+}
+
+// verifyAttestation checks the attestation statement over authData || clientDataHash.
+func verifyAttestation(obj *webauthnAttestationObject, authData *webauthnAuthData, credentialKey *ecdsa.PublicKey, credentialAlg int64, clientDataHash []byte) error {
+	switch obj.Format {
+	case attestationFormatNone:
+		var stmt map[string]any
+		if err := cbor.Unmarshal(obj.AttStmt, &stmt); err != nil || len(stmt) != 0 {
+			return fmt.Errorf("none attestation must have an empty statement")
+		}
+
+		return nil
+	case attestationFormatPacked:
+		var stmt packedAttestationStatement
+		if err := cbor.Unmarshal(obj.AttStmt, &stmt); err != nil {
+			return fmt.Errorf("packed attestation statement invalid: %w", err)
+		}
+
+		signed := append(append([]byte{}, obj.AuthData...), clientDataHash...)
+
+		if len(stmt.X5C) == 0 {
+			if stmt.Alg != credentialAlg {
+				return fmt.Errorf("self attestation alg %d does not match credential alg %d", stmt.Alg, credentialAlg)
+			}
+
+			if !verifyES256(credentialKey, signed, stmt.Sig) {
+				return fmt.Errorf("self attestation signature invalid")
+			}
+
+			return nil
+		}
+
+		if stmt.Alg != coseAlgES256 {
+			return fmt.Errorf("attestation alg %d is not supported", stmt.Alg)
+		}
+
+		cert, err := x509.ParseCertificate(stmt.X5C[0])
+		if err != nil {
+			return fmt.Errorf("attestation certificate invalid: %w", err)
+		}
+
+		if err := checkPackedAttestationCertificate(cert, authData.aaguid); err != nil {
+			return err
+		}
+
+		certKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+		if !ok || !verifyES256(certKey, signed, stmt.Sig) {
+			return fmt.Errorf("attestation signature invalid")
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("attestation format %q is not supported", obj.Format)
+	}
+}
+
+// checkPackedAttestationCertificate applies the certificate requirements of WebAuthn §8.2.1.
+func checkPackedAttestationCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 {
+		return fmt.Errorf("attestation certificate must be version 3")
+	}
+
+	if cert.IsCA {
+		return fmt.Errorf("attestation certificate must not be a CA")
+	}
+
+	if !slices.Contains(cert.Subject.OrganizationalUnit, "Authenticator Attestation") {
+		return fmt.Errorf("attestation certificate subject OU invalid")
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+
+		if ext.Critical {
+			return fmt.Errorf("attestation certificate aaguid extension must not be critical")
+		}
+
+		var certAAGUID []byte
+		if _, err := asn1.Unmarshal(ext.Value, &certAAGUID); err != nil || !bytes.Equal(certAAGUID, aaguid) {
+			return fmt.Errorf("attestation certificate aaguid does not match authenticator data")
+		}
+	}
+
+	return nil
+}
+
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 || bytes.Equal(aaguid, make([]byte, 16)) {
+		return ""
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", aaguid[0:4], aaguid[4:6], aaguid[6:8], aaguid[8:10], aaguid[10:16])
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/invenlore/identity.service/internal/domain"
+	"google.golang.org/grpc/codes"
+)
+
+const testOrigin = "https://id.example.com"
+
+// softAuthenticator is a software ES256 authenticator producing the bytes a browser would hand over.
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	aaguid       []byte
+	rpID         string
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, rpID string) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{
+		t:            t,
+		key:          key,
+		credentialID: credentialID,
+		aaguid:       []byte("0123456789abcdef"),
+		rpID:         rpID,
+	}
+}
+
+func (a *softAuthenticator) credentialIDString() string {
+	return base64.RawURLEncoding.EncodeToString(a.credentialID)
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	raw, err := cbor.Marshal(coseEC2Key{
+		KeyType:   coseKeyTypeEC2,
+		Algorithm: coseAlgES256,
+		Curve:     coseCurveP256,
+		X:         a.key.X.FillBytes(make([]byte, es256CoordBytes)),
+		Y:         a.key.Y.FillBytes(make([]byte, es256CoordBytes)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return raw
+}
+
+func (a *softAuthenticator) authData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	if attested {
+		data = append(data, a.aaguid...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+
+	return data
+}
+
+func (a *softAuthenticator) sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return signature
+}
+
+func clientDataJSON(t *testing.T, ceremony, challenge, origin string) []byte {
+	t.Helper()
+
+	raw, err := json.Marshal(webauthnClientData{Type: ceremony, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func attestationObject(t *testing.T, format string, stmt any, authData []byte) []byte {
+	t.Helper()
+
+	rawStmt, err := cbor.Marshal(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := cbor.Marshal(webauthnAttestationObject{Format: format, AttStmt: rawStmt, AuthData: authData})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+// packedAttestationCert issues a leaf that satisfies WebAuthn §8.2.1 for the given AAGUID.
+func packedAttestationCert(t *testing.T, aaguid []byte) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aaguidExt, err := asn1.Marshal(aaguid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Example Authenticators"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Example Attestation",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions:       []pkix.Extension{{Id: oidFIDOAAGUID, Value: aaguidExt}},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, der
+}
+
+func challengeFromOptions(t *testing.T, options string) string {
+	t.Helper()
+
+	var parsed struct {
+		Challenge        string            `json:"challenge"`
+		AllowCredentials []json.RawMessage `json:"allowCredentials"`
+	}
+
+	if err := json.Unmarshal([]byte(options), &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Challenge == "" {
+		t.Fatal("options carry no challenge")
+	}
+
+	return parsed.Challenge
+}
+
+// register runs a registration ceremony and returns the stored credential id.
+func registerSoftAuthenticator(t *testing.T, env *testAuthEnv, user *domain.User, auth *softAuthenticator, format string) string {
+	t.Helper()
+
+	ctx := context.Background()
+	accessToken := env.accessToken(t, user)
+
+	options, code, err := env.svc.BeginWebAuthnRegistration(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnRegistration: %v (%s)", err, code)
+	}
+
+	clientData := clientDataJSON(t, webauthnTypeCreate, challengeFromOptions(t, options), testOrigin)
+	authData := auth.authData(authDataFlagUserPresent|authDataFlagUserVerified|authDataFlagAttestedCredential, true)
+
+	var stmt any
+	switch format {
+	case attestationFormatNone:
+		stmt = map[string]any{}
+	case "packed-self":
+		format = attestationFormatPacked
+		stmt = packedAttestationStatement{Alg: coseAlgES256, Sig: auth.sign(auth.key, authData, clientData)}
+	case attestationFormatPacked:
+		certKey, certDER := packedAttestationCert(t, auth.aaguid)
+		stmt = packedAttestationStatement{Alg: coseAlgES256, Sig: auth.sign(certKey, authData, clientData), X5C: [][]byte{certDER}}
+	}
+
+	credentialID, code, err := env.svc.FinishWebAuthnRegistration(ctx, accessToken, "test key", clientData, attestationObject(t, format, stmt, authData), []string{"usb"})
+	if err != nil {
+		t.Fatalf("FinishWebAuthnRegistration(%s): %v (%s)", format, err, code)
+	}
+
+	if credentialID != auth.credentialIDString() {
+		t.Fatalf("credential id = %q, want %q", credentialID, auth.credentialIDString())
+	}
+
+	return credentialID
+}
+
+// assert runs a login ceremony with the authenticator's current sign count.
+func assertSoftAuthenticator(t *testing.T, env *testAuthEnv, auth *softAuthenticator, flags byte, origin string) (codes.Code, error) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	options, code, err := env.svc.BeginWebAuthnLogin(ctx, "198.51.100.7")
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v (%s)", err, code)
+	}
+
+	clientData := clientDataJSON(t, webauthnTypeGet, challengeFromOptions(t, options), origin)
+	authData := auth.authData(flags, false)
+	signature := auth.sign(auth.key, authData, clientData)
+
+	resp, code, err := env.svc.FinishWebAuthnLogin(ctx, auth.credentialIDString(), clientData, authData, signature, nil, "test-agent", "198.51.100.7")
+	if err == nil && (resp == nil || resp.AccessToken == "" || resp.RefreshToken == "") {
+		t.Fatal("successful assertion returned no tokens")
+	}
+
+	return code, err
+}
+
+func TestWebAuthnRegistrationAttestationFormats(t *testing.T) {
+	for _, format := range []string{attestationFormatNone, "packed-self", attestationFormatPacked} {
+		t.Run(format, func(t *testing.T) {
+			env := newTestAuthEnv(t, testAuthSettings())
+			user := env.addUser(t, "passkey@example.com", "correct horse battery")
+			auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+
+			credentialID := registerSoftAuthenticator(t, env, user, auth, format)
+
+			stored, err := env.repo.FindWebAuthnCredential(context.Background(), credentialID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stored.UserID != user.Id || stored.Algorithm != coseAlgES256 {
+				t.Fatalf("stored credential = %+v", stored)
+			}
+
+			auth.signCount = 1
+			if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent|authDataFlagUserVerified, testOrigin); err != nil {
+				t.Fatalf("assertion after %s registration: %v (%s)", format, err, code)
+			}
+		})
+	}
+}
+
+func TestWebAuthnRegistrationRejectsTamperedPackedSignature(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+	ctx := context.Background()
+	accessToken := env.accessToken(t, user)
+
+	options, _, err := env.svc.BeginWebAuthnRegistration(ctx, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientData := clientDataJSON(t, webauthnTypeCreate, challengeFromOptions(t, options), testOrigin)
+	authData := auth.authData(authDataFlagUserPresent|authDataFlagAttestedCredential, true)
+
+	// Signed over different client data than the one presented.
+	otherClientData := clientDataJSON(t, webauthnTypeCreate, "other", testOrigin)
+	stmt := packedAttestationStatement{Alg: coseAlgES256, Sig: auth.sign(auth.key, authData, otherClientData)}
+
+	_, code, err := env.svc.FinishWebAuthnRegistration(ctx, accessToken, "", clientData, attestationObject(t, attestationFormatPacked, stmt, authData), nil)
+	if err == nil || code != codes.InvalidArgument {
+		t.Fatalf("tampered packed attestation: code %s, err %v", code, err)
+	}
+}
+
+func TestWebAuthnRegistrationRejectsForeignOrigin(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+	ctx := context.Background()
+	accessToken := env.accessToken(t, user)
+
+	options, _, err := env.svc.BeginWebAuthnRegistration(ctx, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientData := clientDataJSON(t, webauthnTypeCreate, challengeFromOptions(t, options), "https://evil.example.net")
+	authData := auth.authData(authDataFlagUserPresent|authDataFlagAttestedCredential, true)
+
+	_, code, err := env.svc.FinishWebAuthnRegistration(ctx, accessToken, "", clientData, attestationObject(t, attestationFormatNone, map[string]any{}, authData), nil)
+	if err == nil || code != codes.InvalidArgument {
+		t.Fatalf("foreign origin: code %s, err %v", code, err)
+	}
+}
+
+func TestWebAuthnAssertionRejectsForeignOriginAndRPID(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+	registerSoftAuthenticator(t, env, user, auth, attestationFormatNone)
+
+	auth.signCount = 1
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, "https://evil.example.net"); err == nil || code != codes.InvalidArgument {
+		t.Fatalf("foreign origin: code %s, err %v", code, err)
+	}
+
+	auth.rpID = "evil.example.net"
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err == nil || code != codes.Unauthenticated {
+		t.Fatalf("foreign rp id hash: code %s, err %v", code, err)
+	}
+
+	auth.rpID = env.svc.webauthnRPID
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err != nil {
+		t.Fatalf("valid assertion after rejected ones: %v (%s)", err, code)
+	}
+}
+
+func TestWebAuthnAssertionDetectsClonedCredential(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+	credentialID := registerSoftAuthenticator(t, env, user, auth, attestationFormatNone)
+
+	auth.signCount = 5
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err != nil {
+		t.Fatalf("first assertion: %v (%s)", err, code)
+	}
+
+	// A copy of the key that has signed fewer times than the original.
+	auth.signCount = 3
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err == nil || code != codes.PermissionDenied {
+		t.Fatalf("replayed sign count: code %s, err %v", code, err)
+	}
+
+	stored, err := env.repo.FindWebAuthnCredential(context.Background(), credentialID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.CloneDetectedAt == nil {
+		t.Fatal("credential was not marked as cloned")
+	}
+
+	if !slices.Contains(env.events.types(), string(SecurityEventWebAuthnClone)) {
+		t.Fatalf("events = %v, want a clone event", env.events.types())
+	}
+
+	// The credential stays refused even with a sign count that moves forward again.
+	auth.signCount = 10
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err == nil || code != codes.PermissionDenied {
+		t.Fatalf("assertion after clone detection: code %s, err %v", code, err)
+	}
+}
+
+func TestWebAuthnAssertionRequiresUserVerificationWithTOTP(t *testing.T) {
+	env := newTestAuthEnv(t, testAuthSettings())
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	auth := newSoftAuthenticator(t, env.svc.webauthnRPID)
+	registerSoftAuthenticator(t, env, user, auth, attestationFormatNone)
+
+	enabledAt := time.Now().UTC()
+	env.repo.mu.Lock()
+	env.repo.users[user.Id].MFA = &domain.UserMFA{TOTPSecret: "sealed", TOTPEnabledAt: &enabledAt}
+	env.repo.mu.Unlock()
+
+	auth.signCount = 1
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent, testOrigin); err == nil || code != codes.Unauthenticated {
+		t.Fatalf("presence-only assertion with totp: code %s, err %v", code, err)
+	}
+
+	auth.signCount = 2
+	if code, err := assertSoftAuthenticator(t, env, auth, authDataFlagUserPresent|authDataFlagUserVerified, testOrigin); err != nil {
+		t.Fatalf("verified assertion with totp: %v (%s)", err, code)
+	}
+}
+
+func TestBeginWebAuthnLoginIsUnboundAndRateLimited(t *testing.T) {
+	authSettings := testAuthSettings()
+	authSettings.WebAuthnLoginRateLimit = 3
+
+	env := newTestAuthEnv(t, authSettings)
+	user := env.addUser(t, "passkey@example.com", "correct horse battery")
+	registerSoftAuthenticator(t, env, user, newSoftAuthenticator(t, env.svc.webauthnRPID), attestationFormatNone)
+
+	ctx := context.Background()
+
+	for i := range 3 {
+		options, code, err := env.svc.BeginWebAuthnLogin(ctx, "203.0.113.9")
+		if err != nil {
+			t.Fatalf("call %d: %v (%s)", i, err, code)
+		}
+
+		var parsed webauthnRequestOptions
+		if err := json.Unmarshal([]byte(options), &parsed); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(parsed.AllowCredentials) != 0 {
+			t.Fatalf("options list credentials: %v", parsed.AllowCredentials)
+		}
+	}
+
+	if _, code, err := env.svc.BeginWebAuthnLogin(ctx, "203.0.113.9"); err == nil || code != codes.ResourceExhausted {
+		t.Fatalf("call over the limit: code %s, err %v", code, err)
+	}
+
+	if _, _, err := env.svc.BeginWebAuthnLogin(ctx, "203.0.113.10"); err != nil {
+		t.Fatalf("other ip: %v", err)
+	}
+
+	if got := env.repo.countActionTokens(domain.ActionTokenPurposeWebAuthnLogin); got != 4 {
+		t.Fatalf("stored login challenges = %d, want 4", got)
+	}
+}
@@ -0,0 +1,290 @@
+package settings
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+// Settings holds identity-specific knobs that are not part of the shared core config.
+type Settings struct {
+	Auth   AuthSettings
+	Notify NotifySettings
+}
+
+type AuthSettings struct {
+	RefreshReuseRevokeAllSessions bool          `env:"AUTH_REFRESH_REUSE_REVOKE_ALL_SESSIONS" envDefault:"false"`
+	RefreshGraceWindow            time.Duration `env:"AUTH_REFRESH_GRACE_WINDOW" envDefault:"10s"`
+	PasswordResetTTL              time.Duration `env:"AUTH_PASSWORD_RESET_TTL" envDefault:"30m"`
+	PasswordResetURL              string        `env:"AUTH_PASSWORD_RESET_URL"`
+	EmailVerificationPolicy       string        `env:"AUTH_EMAIL_VERIFICATION_POLICY" envDefault:"claim"`
+	EmailVerificationTTL          time.Duration `env:"AUTH_EMAIL_VERIFICATION_TTL" envDefault:"24h"`
+	EmailVerificationURL          string        `env:"AUTH_EMAIL_VERIFICATION_URL"`
+	MFAEncryptionKeyBase64        string        `env:"AUTH_MFA_ENCRYPTION_KEY"`
+	MFAIssuer                     string        `env:"AUTH_MFA_ISSUER" envDefault:"Invenlore"`
+	MFAChallengeTTL               time.Duration `env:"AUTH_MFA_CHALLENGE_TTL" envDefault:"5m"`
+	WebAuthnRPID                  string        `env:"AUTH_WEBAUTHN_RP_ID"`
+	WebAuthnRPName                string        `env:"AUTH_WEBAUTHN_RP_NAME" envDefault:"Invenlore"`
+	WebAuthnOrigins               []string      `env:"AUTH_WEBAUTHN_ORIGINS" envSeparator:","`
+	WebAuthnChallengeTTL          time.Duration `env:"AUTH_WEBAUTHN_CHALLENGE_TTL" envDefault:"5m"`
+	WebAuthnLoginRateLimit        int           `env:"AUTH_WEBAUTHN_LOGIN_RATE_LIMIT" envDefault:"30"`
+	WebAuthnLoginRateWindow       time.Duration `env:"AUTH_WEBAUTHN_LOGIN_RATE_WINDOW" envDefault:"1m"`
+	LoginThrottleEmailThreshold   int64         `env:"AUTH_LOGIN_THROTTLE_EMAIL_THRESHOLD" envDefault:"5"`
+	LoginThrottleIPThreshold      int64         `env:"AUTH_LOGIN_THROTTLE_IP_THRESHOLD" envDefault:"20"`
+	LoginThrottleBaseDelay        time.Duration `env:"AUTH_LOGIN_THROTTLE_BASE_DELAY" envDefault:"1s"`
+	LoginThrottleMaxDelay         time.Duration `env:"AUTH_LOGIN_THROTTLE_MAX_DELAY" envDefault:"15m"`
+	LoginThrottleWindow           time.Duration `env:"AUTH_LOGIN_THROTTLE_WINDOW" envDefault:"1h"`
+	TrustedProxies                []string      `env:"AUTH_TRUSTED_PROXIES" envSeparator:","`
+	PasswordMinLength             int           `env:"AUTH_PASSWORD_MIN_LENGTH" envDefault:"8"`
+	PasswordMaxLength             int           `env:"AUTH_PASSWORD_MAX_LENGTH" envDefault:"256"`
+	PasswordRequireUpper          bool          `env:"AUTH_PASSWORD_REQUIRE_UPPER" envDefault:"false"`
+	PasswordRequireLower          bool          `env:"AUTH_PASSWORD_REQUIRE_LOWER" envDefault:"false"`
+	PasswordRequireDigit          bool          `env:"AUTH_PASSWORD_REQUIRE_DIGIT" envDefault:"false"`
+	PasswordRequireSymbol         bool          `env:"AUTH_PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+	PasswordDisallowPersonalInfo  bool          `env:"AUTH_PASSWORD_DISALLOW_PERSONAL_INFO" envDefault:"true"`
+	PasswordMaxRepeated           int           `env:"AUTH_PASSWORD_MAX_REPEATED" envDefault:"0"`
+	PasswordBreachedListPath      string        `env:"AUTH_PASSWORD_BREACHED_LIST_PATH"`
+	PasswordArgon2Time            uint32        `env:"AUTH_PASSWORD_ARGON2_TIME" envDefault:"3"`
+	PasswordArgon2MemoryKiB       uint32        `env:"AUTH_PASSWORD_ARGON2_MEMORY_KIB" envDefault:"65536"`
+	PasswordArgon2Threads         uint8         `env:"AUTH_PASSWORD_ARGON2_THREADS" envDefault:"2"`
+	PasswordHashMaxConcurrent     int64         `env:"AUTH_PASSWORD_HASH_MAX_CONCURRENT" envDefault:"4"`
+	PasswordHashQueueTimeout      time.Duration `env:"AUTH_PASSWORD_HASH_QUEUE_TIMEOUT" envDefault:"2s"`
+	SigningKeyAlg                 string        `env:"AUTH_SIGNING_KEY_ALG" envDefault:"RS256"`
+	KeyEncryptionKeys             string        `env:"AUTH_KEY_ENCRYPTION_KEYS"`
+	KeyEncryptionKeysFile         string        `env:"AUTH_KEY_ENCRYPTION_KEYS_FILE"`
+	KeyEncryptionKeyID            string        `env:"AUTH_KEY_ENCRYPTION_KEY_ID"`
+	KeyProvider                   string        `env:"AUTH_KEY_PROVIDER" envDefault:"mongo"`
+	KeyFileDir                    string        `env:"AUTH_KEY_FILE_DIR"`
+	KeyFileActiveKid              string        `env:"AUTH_KEY_FILE_ACTIVE_KID"`
+	KeyFileReloadInterval         time.Duration `env:"AUTH_KEY_FILE_RELOAD_INTERVAL" envDefault:"30s"`
+
+	// MFAEncryptionKey is decoded from MFAEncryptionKeyBase64; MFA enrollment is unavailable without it.
+	MFAEncryptionKey []byte `env:"-"`
+	// KeyEncryptionKeyring maps KEK ids to 32-byte keys, parsed from KeyEncryptionKeys and
+	// KeyEncryptionKeysFile; signing private keys are stored in plaintext without it.
+	KeyEncryptionKeyring map[string][]byte `env:"-"`
+	// TrustedProxyPrefixes is parsed from TrustedProxies. x-forwarded-for is only honoured on
+	// connections from these addresses; without any, the client IP is the connection peer.
+	TrustedProxyPrefixes []netip.Prefix `env:"-"`
+}
+
+const (
+	// EmailVerificationPolicyRequire blocks Login until the email is verified.
+	EmailVerificationPolicyRequire = "require"
+	// EmailVerificationPolicyClaim allows Login and exposes the state as an email_verified claim.
+	EmailVerificationPolicyClaim = "claim"
+)
+
+const (
+	// KeyProviderMongo signs with keys generated, rotated and stored in the auth_keys collection.
+	KeyProviderMongo = "mongo"
+	// KeyProviderFile signs with PEM keys mounted in AUTH_KEY_FILE_DIR; rotation is done by the deployment.
+	KeyProviderFile = "file"
+	// KeyProviderExternal is reserved for signing through a KMS/HSM client. No client ships in this
+	// binary, so Load rejects it rather than starting a service that cannot sign.
+	KeyProviderExternal = "external"
+)
+
+// Upper bounds on argon2id costs. They apply to the configured target and to every stored hash,
+// so one hash cannot make a login arbitrarily expensive.
+const (
+	MaxPasswordArgon2Time      = 16
+	MaxPasswordArgon2MemoryKiB = 1 << 20
+	MaxPasswordArgon2Threads   = 16
+)
+
+type NotifySettings struct {
+	Driver     string `env:"NOTIFY_DRIVER" envDefault:"log"`
+	OutboxPath string `env:"NOTIFY_OUTBOX_PATH" envDefault:"outbox.jsonl"`
+}
+
+func Load() (*Settings, error) {
+	s, err := env.ParseAs[Settings]()
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Auth.EmailVerificationPolicy {
+	case EmailVerificationPolicyRequire, EmailVerificationPolicyClaim:
+	default:
+		return nil, fmt.Errorf("AUTH_EMAIL_VERIFICATION_POLICY must be %q or %q", EmailVerificationPolicyRequire, EmailVerificationPolicyClaim)
+	}
+
+	// Without a grace window a client that merely loses a concurrent refresh would present a
+	// just-rotated token, which is indistinguishable from reuse and revokes the whole family.
+	if s.Auth.RefreshGraceWindow <= 0 {
+		return nil, fmt.Errorf("AUTH_REFRESH_GRACE_WINDOW must be positive")
+	}
+
+	switch s.Auth.SigningKeyAlg {
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("AUTH_SIGNING_KEY_ALG must be RS256, ES256 or EdDSA")
+	}
+
+	switch s.Auth.KeyProvider {
+	case KeyProviderMongo:
+	case KeyProviderFile:
+		if s.Auth.KeyFileDir == "" {
+			return nil, fmt.Errorf("AUTH_KEY_FILE_DIR is required when AUTH_KEY_PROVIDER is %q", KeyProviderFile)
+		}
+	case KeyProviderExternal:
+		return nil, fmt.Errorf("AUTH_KEY_PROVIDER %q is not supported by this binary: it has no KMS/HSM signer client, use %q or %q",
+			KeyProviderExternal, KeyProviderMongo, KeyProviderFile)
+	default:
+		return nil, fmt.Errorf("AUTH_KEY_PROVIDER must be %q or %q", KeyProviderMongo, KeyProviderFile)
+	}
+
+	if s.Auth.WebAuthnLoginRateLimit < 1 || s.Auth.WebAuthnLoginRateWindow <= 0 {
+		return nil, fmt.Errorf("AUTH_WEBAUTHN_LOGIN_RATE_LIMIT and AUTH_WEBAUTHN_LOGIN_RATE_WINDOW must be positive")
+	}
+
+	// The maximum is what keeps oversized payloads away from argon2, so it cannot be switched off.
+	if s.Auth.PasswordMinLength < 1 || s.Auth.PasswordMaxLength < s.Auth.PasswordMinLength {
+		return nil, fmt.Errorf("AUTH_PASSWORD_MIN_LENGTH must be positive and AUTH_PASSWORD_MAX_LENGTH at least as large")
+	}
+
+	if s.Auth.PasswordArgon2Time == 0 || s.Auth.PasswordArgon2Threads == 0 {
+		return nil, fmt.Errorf("AUTH_PASSWORD_ARGON2_TIME and AUTH_PASSWORD_ARGON2_THREADS must be positive")
+	}
+
+	if s.Auth.PasswordArgon2Time > MaxPasswordArgon2Time || s.Auth.PasswordArgon2Threads > MaxPasswordArgon2Threads || s.Auth.PasswordArgon2MemoryKiB > MaxPasswordArgon2MemoryKiB {
+		return nil, fmt.Errorf("AUTH_PASSWORD_ARGON2_TIME, AUTH_PASSWORD_ARGON2_MEMORY_KIB and AUTH_PASSWORD_ARGON2_THREADS must be at most %d, %d and %d",
+			MaxPasswordArgon2Time, MaxPasswordArgon2MemoryKiB, MaxPasswordArgon2Threads)
+	}
+
+	// argon2 silently raises memory below 8 KiB per lane, which would make stored parameters lie.
+	if s.Auth.PasswordArgon2MemoryKiB < 8*uint32(s.Auth.PasswordArgon2Threads) {
+		return nil, fmt.Errorf("AUTH_PASSWORD_ARGON2_MEMORY_KIB must be at least 8 per thread")
+	}
+
+	if s.Auth.PasswordHashMaxConcurrent < 1 || s.Auth.PasswordHashQueueTimeout <= 0 {
+		return nil, fmt.Errorf("AUTH_PASSWORD_HASH_MAX_CONCURRENT and AUTH_PASSWORD_HASH_QUEUE_TIMEOUT must be positive")
+	}
+
+	if s.Auth.MFAEncryptionKeyBase64 != "" {
+		key, err := base64.StdEncoding.DecodeString(s.Auth.MFAEncryptionKeyBase64)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_MFA_ENCRYPTION_KEY must be base64: %w", err)
+		}
+
+		if len(key) != 32 {
+			return nil, fmt.Errorf("AUTH_MFA_ENCRYPTION_KEY must decode to 32 bytes, got %d", len(key))
+		}
+
+		s.Auth.MFAEncryptionKey = key
+	}
+
+	if err := loadKeyEncryptionKeys(&s.Auth); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range s.Auth.TrustedProxies {
+		prefix, err := parseTrustedProxy(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_TRUSTED_PROXIES entry %q must be an IP address or CIDR: %w", entry, err)
+		}
+
+		s.Auth.TrustedProxyPrefixes = append(s.Auth.TrustedProxyPrefixes, prefix)
+	}
+
+	return &s, nil
+}
+
+// parseTrustedProxy accepts a CIDR or a bare address, which stands for a single host.
+func parseTrustedProxy(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// loadKeyEncryptionKeys reads "id:base64key" entries, comma or newline separated, from the env var
+// and the file. Several entries allow a KEK rotation: the new one becomes current while the old one
+// stays around to decrypt until every key has been rewrapped.
+func loadKeyEncryptionKeys(auth *AuthSettings) error {
+	entries := auth.KeyEncryptionKeys
+
+	if auth.KeyEncryptionKeysFile != "" {
+		raw, err := os.ReadFile(auth.KeyEncryptionKeysFile)
+		if err != nil {
+			return fmt.Errorf("AUTH_KEY_ENCRYPTION_KEYS_FILE read failed: %w", err)
+		}
+
+		entries += "\n" + string(raw)
+	}
+
+	keyring := make(map[string][]byte)
+	for _, entry := range strings.FieldsFunc(entries, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			return fmt.Errorf("key encryption key entries must be id:base64key")
+		}
+
+		id = strings.TrimSpace(id)
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return fmt.Errorf("key encryption key %q must be base64: %w", id, err)
+		}
+
+		if len(key) != 32 {
+			return fmt.Errorf("key encryption key %q must decode to 32 bytes, got %d", id, len(key))
+		}
+
+		if _, exists := keyring[id]; exists {
+			return fmt.Errorf("key encryption key %q is defined twice", id)
+		}
+
+		keyring[id] = key
+	}
+
+	if len(keyring) == 0 {
+		if auth.KeyEncryptionKeyID != "" {
+			return fmt.Errorf("AUTH_KEY_ENCRYPTION_KEY_ID is set but no key encryption keys are configured")
+		}
+
+		return nil
+	}
+
+	if auth.KeyEncryptionKeyID == "" {
+		if len(keyring) > 1 {
+			return fmt.Errorf("AUTH_KEY_ENCRYPTION_KEY_ID must name the current key when several are configured")
+		}
+
+		for id := range keyring {
+			auth.KeyEncryptionKeyID = id
+		}
+	}
+
+	if _, ok := keyring[auth.KeyEncryptionKeyID]; !ok {
+		return fmt.Errorf("AUTH_KEY_ENCRYPTION_KEY_ID %q is not among the configured keys", auth.KeyEncryptionKeyID)
+	}
+
+	auth.KeyEncryptionKeyring = keyring
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invenlore/core/pkg/errmodel"
+	"github.com/invenlore/identity.service/internal/service"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const errorInfoDomain = "identity.invenlore"
+
+// serviceError maps a service error to a gRPC status, attaching ErrorInfo for errors clients
+// are expected to branch on; everything else goes through errmodel unchanged.
+func serviceError(ctx context.Context, code codes.Code, err error) error {
+	var statusErr *service.AccountStatusError
+	if errors.As(err, &statusErr) {
+		metadata := map[string]string{"status": string(statusErr.Status)}
+
+		if statusErr.Reason != "" {
+			metadata["reason"] = statusErr.Reason
+		}
+
+		if statusErr.SuspendedUntil != nil {
+			metadata["suspended_until"] = statusErr.SuspendedUntil.UTC().Format(time.RFC3339)
+		}
+
+		return errorWithInfo(ctx, code, err.Error(), "ACCOUNT_"+strings.ToUpper(string(statusErr.Status)), metadata)
+	}
+
+	var throttledErr *service.LoginThrottledError
+	if errors.As(err, &throttledErr) {
+		retryAfter := throttledErr.RetryAfter.Round(time.Second)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+
+		metadata := map[string]string{
+			"scope":               string(throttledErr.Kind),
+			"retry_after_seconds": strconv.FormatInt(int64(retryAfter/time.Second), 10),
+		}
+
+		return errorWithInfo(ctx, code, err.Error(), "LOGIN_THROTTLED", metadata, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	}
+
+	var policyErr *service.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(policyErr.Violations))
+		for _, violation := range policyErr.Violations {
+			violations = append(violations, errmodel.FieldViolation(policyErr.Field, violation.Description))
+		}
+
+		return errmodel.BadRequest(ctx, err.Error(), violations...)
+	}
+
+	var verifyErr *service.EmailNotVerifiedError
+	if errors.As(err, &verifyErr) {
+		return errorWithInfo(ctx, code, err.Error(), "EMAIL_NOT_VERIFIED", nil)
+	}
+
+	return errmodel.Error(ctx, code, err.Error())
+}
+
+// errorWithInfo builds the status through errmodel like every other error and only appends the
+// ErrorInfo (plus any extra details such as RetryInfo) to what errmodel produced.
+func errorWithInfo(ctx context.Context, code codes.Code, message, reason string, metadata map[string]string, extra ...protoadapt.MessageV1) error {
+	base := errmodel.Error(ctx, code, message)
+
+	st, ok := status.FromError(base)
+	if !ok {
+		return base
+	}
+
+	details := append([]protoadapt.MessageV1{&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   errorInfoDomain,
+		Metadata: metadata,
+	}}, extra...)
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return base
+	}
+
+	return withDetails.Err()
+}
@@ -2,14 +2,18 @@ package transport
 
 import (
 	"context"
+	"net/netip"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/invenlore/core/pkg/errmodel"
+	"github.com/invenlore/identity.service/internal/domain"
 	common_v1 "github.com/invenlore/proto/pkg/common/v1"
 	identity_v1 "github.com/invenlore/proto/pkg/identity/v1"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 )
 
 var v = validator.New(validator.WithRequiredStructEnabled())
@@ -19,11 +23,38 @@ type addUserInput struct {
 	Email string `validate:"required,email,max=254"`
 }
 
+type updateProfileInput struct {
+	Name string `validate:"required,min=1,max=100"`
+}
+
+type getUserBriefInput struct {
+	Ids []string `validate:"required,min=1,max=100,dive,required"`
+}
+
+type setUserStatusInput struct {
+	Status string `validate:"required,oneof=active suspended disabled"`
+	Reason string `validate:"max=500"`
+}
+
+type listLoginThrottlesInput struct {
+	Limit int32 `validate:"min=0,max=500"`
+}
+
+type importUsersInput struct {
+	Users int `validate:"min=1,max=500"`
+}
+
+type authorizeInput struct {
+	Subject  string `validate:"required,mongodb"`
+	Resource string `validate:"required,max=200"`
+	Action   string `validate:"required,max=100"`
+}
+
 // PUBLIC SCOPE
 func (s *GRPCIdentityServer) Register(ctx context.Context, req *identity_v1.RegisterRequest) (*identity_v1.RegisterResponse, error) {
 	resp, code, err := s.authSvc.Register(ctx, req)
 	if err != nil {
-		return nil, errmodel.Error(ctx, code, err.Error())
+		return nil, serviceError(ctx, code, err)
 	}
 
 	return resp, nil
@@ -31,19 +62,82 @@ func (s *GRPCIdentityServer) Register(ctx context.Context, req *identity_v1.Regi
 
 // PUBLIC SCOPE
 func (s *GRPCIdentityServer) Login(ctx context.Context, req *identity_v1.LoginRequest) (*identity_v1.LoginResponse, error) {
-	resp, code, err := s.authSvc.Login(ctx, req, userAgentFromContext(ctx), ipFromContext(ctx))
+	resp, code, err := s.authSvc.Login(ctx, req, userAgentFromContext(ctx), ipFromContext(ctx, s.trustedProxies))
 	if err != nil {
-		return nil, errmodel.Error(ctx, code, err.Error())
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return resp, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) VerifyMFA(ctx context.Context, req *identity_v1.VerifyMFARequest) (*identity_v1.VerifyMFAResponse, error) {
+	if req == nil || strings.TrimSpace(req.ChallengeToken) == "" {
+		return nil, errmodel.BadRequest(ctx, "challenge token is required", errmodel.FieldViolation("challenge_token", "challenge token is required"))
+	}
+
+	if strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "" {
+		return nil, errmodel.BadRequest(ctx, "code or recovery code is required",
+			errmodel.FieldViolation("code", "code or recovery code is required"),
+			errmodel.FieldViolation("recovery_code", "code or recovery code is required"),
+		)
+	}
+
+	resp, code, err := s.authSvc.VerifyMFA(ctx, req.ChallengeToken, req.Code, req.RecoveryCode, userAgentFromContext(ctx), ipFromContext(ctx, s.trustedProxies))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
 	}
 
 	return resp, nil
 }
 
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) BeginWebAuthnLogin(ctx context.Context, req *identity_v1.BeginWebAuthnLoginRequest) (*identity_v1.BeginWebAuthnLoginResponse, error) {
+	// req.Email is ignored; see IdentityAuthService.BeginWebAuthnLogin.
+	options, code, err := s.authSvc.BeginWebAuthnLogin(ctx, ipFromContext(ctx, s.trustedProxies))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.BeginWebAuthnLoginResponse{OptionsJson: options}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) FinishWebAuthnLogin(ctx context.Context, req *identity_v1.FinishWebAuthnLoginRequest) (*identity_v1.FinishWebAuthnLoginResponse, error) {
+	if req == nil || strings.TrimSpace(req.CredentialId) == "" {
+		return nil, errmodel.BadRequest(ctx, "credential id is required", errmodel.FieldViolation("credential_id", "credential id is required"))
+	}
+
+	if len(req.ClientDataJson) == 0 {
+		return nil, errmodel.BadRequest(ctx, "client data is required", errmodel.FieldViolation("client_data_json", "client data is required"))
+	}
+
+	if len(req.AuthenticatorData) == 0 {
+		return nil, errmodel.BadRequest(ctx, "authenticator data is required", errmodel.FieldViolation("authenticator_data", "authenticator data is required"))
+	}
+
+	if len(req.Signature) == 0 {
+		return nil, errmodel.BadRequest(ctx, "signature is required", errmodel.FieldViolation("signature", "signature is required"))
+	}
+
+	resp, code, err := s.authSvc.FinishWebAuthnLogin(ctx, req.CredentialId, req.ClientDataJson, req.AuthenticatorData, req.Signature, req.UserHandle, userAgentFromContext(ctx), ipFromContext(ctx, s.trustedProxies))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.FinishWebAuthnLoginResponse{
+		AccessToken:      resp.AccessToken,
+		RefreshToken:     resp.RefreshToken,
+		ExpiresInSeconds: resp.ExpiresInSeconds,
+		User:             resp.User,
+	}, nil
+}
+
 // PUBLIC SCOPE
 func (s *GRPCIdentityServer) Refresh(ctx context.Context, req *identity_v1.RefreshRequest) (*identity_v1.RefreshResponse, error) {
-	resp, code, err := s.authSvc.Refresh(ctx, req, userAgentFromContext(ctx), ipFromContext(ctx))
+	resp, code, err := s.authSvc.Refresh(ctx, req, userAgentFromContext(ctx), ipFromContext(ctx, s.trustedProxies))
 	if err != nil {
-		return nil, errmodel.Error(ctx, code, err.Error())
+		return nil, serviceError(ctx, code, err)
 	}
 
 	return resp, nil
@@ -53,20 +147,238 @@ func (s *GRPCIdentityServer) Refresh(ctx context.Context, req *identity_v1.Refre
 func (s *GRPCIdentityServer) Logout(ctx context.Context, req *identity_v1.LogoutRequest) (*identity_v1.LogoutResponse, error) {
 	code, err := s.authSvc.Logout(ctx, req)
 	if err != nil {
-		return nil, errmodel.Error(ctx, code, err.Error())
+		return nil, serviceError(ctx, code, err)
 	}
 
 	return &identity_v1.LogoutResponse{}, nil
 }
 
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) RequestPasswordReset(ctx context.Context, req *identity_v1.RequestPasswordResetRequest) (*identity_v1.RequestPasswordResetResponse, error) {
+	if req == nil || strings.TrimSpace(req.Email) == "" {
+		return nil, errmodel.BadRequest(ctx, "email is required", errmodel.FieldViolation("email", "email is required"))
+	}
+
+	code, err := s.authSvc.RequestPasswordReset(ctx, req.Email, ipFromContext(ctx, s.trustedProxies))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.RequestPasswordResetResponse{}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) ConfirmPasswordReset(ctx context.Context, req *identity_v1.ConfirmPasswordResetRequest) (*identity_v1.ConfirmPasswordResetResponse, error) {
+	if req == nil || strings.TrimSpace(req.Token) == "" {
+		return nil, errmodel.BadRequest(ctx, "token is required", errmodel.FieldViolation("token", "token is required"))
+	}
+
+	if strings.TrimSpace(req.NewPassword) == "" {
+		return nil, errmodel.BadRequest(ctx, "new password is required", errmodel.FieldViolation("new_password", "new password is required"))
+	}
+
+	code, err := s.authSvc.ConfirmPasswordReset(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.ConfirmPasswordResetResponse{}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) VerifyEmail(ctx context.Context, req *identity_v1.VerifyEmailRequest) (*identity_v1.VerifyEmailResponse, error) {
+	if req == nil || strings.TrimSpace(req.Token) == "" {
+		return nil, errmodel.BadRequest(ctx, "token is required", errmodel.FieldViolation("token", "token is required"))
+	}
+
+	code, err := s.authSvc.VerifyEmail(ctx, req.Token)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.VerifyEmailResponse{}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) ResendVerification(ctx context.Context, req *identity_v1.ResendVerificationRequest) (*identity_v1.ResendVerificationResponse, error) {
+	if req == nil || strings.TrimSpace(req.Email) == "" {
+		return nil, errmodel.BadRequest(ctx, "email is required", errmodel.FieldViolation("email", "email is required"))
+	}
+
+	code, err := s.authSvc.ResendVerification(ctx, req.Email, ipFromContext(ctx, s.trustedProxies))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.ResendVerificationResponse{}, nil
+}
+
 // PUBLIC SCOPE
 func (s *GRPCIdentityServer) GetProfile(ctx context.Context, req *identity_v1.GetProfileRequest) (*identity_v1.GetProfileResponse, error) {
-	return nil, errmodel.Error(ctx, codes.Unimplemented, "get profile is not implemented")
+	user, code, err := s.authSvc.GetProfile(ctx, bearerTokenFromContext(ctx))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.GetProfileResponse{User: user}, nil
 }
 
 // PUBLIC SCOPE
 func (s *GRPCIdentityServer) UpdateProfile(ctx context.Context, req *identity_v1.UpdateProfileRequest) (*identity_v1.UpdateProfileResponse, error) {
-	return nil, errmodel.Error(ctx, codes.Unimplemented, "update profile is not implemented")
+	if req == nil {
+		return nil, errmodel.BadRequest(ctx, "name is required", errmodel.FieldViolation("name", "name is required"))
+	}
+
+	in := updateProfileInput{
+		Name: strings.TrimSpace(req.Name),
+	}
+
+	if err := v.Struct(in); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid profile", errmodel.FieldViolation("name", err.Error()))
+	}
+
+	user, code, err := s.authSvc.UpdateProfile(ctx, bearerTokenFromContext(ctx), domain.UserProfileUpdate{Name: in.Name})
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.UpdateProfileResponse{User: user}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) ListSessions(ctx context.Context, req *identity_v1.ListSessionsRequest) (*identity_v1.ListSessionsResponse, error) {
+	sessions, code, err := s.authSvc.ListSessions(ctx, bearerTokenFromContext(ctx))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.ListSessionsResponse{Sessions: sessions}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) RevokeSession(ctx context.Context, req *identity_v1.RevokeSessionRequest) (*identity_v1.RevokeSessionResponse, error) {
+	if req == nil || strings.TrimSpace(req.SessionId) == "" {
+		return nil, errmodel.BadRequest(ctx, "session id is required", errmodel.FieldViolation("session_id", "session id is required"))
+	}
+
+	code, err := s.authSvc.RevokeSession(ctx, bearerTokenFromContext(ctx), strings.TrimSpace(req.SessionId))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.RevokeSessionResponse{}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) RevokeOtherSessions(ctx context.Context, req *identity_v1.RevokeOtherSessionsRequest) (*identity_v1.RevokeOtherSessionsResponse, error) {
+	refreshToken := ""
+	if req != nil {
+		refreshToken = req.RefreshToken
+	}
+
+	count, code, err := s.authSvc.RevokeOtherSessions(ctx, bearerTokenFromContext(ctx), refreshToken)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.RevokeOtherSessionsResponse{RevokedCount: count}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) ChangePassword(ctx context.Context, req *identity_v1.ChangePasswordRequest) (*identity_v1.ChangePasswordResponse, error) {
+	if req == nil || req.CurrentPassword == "" {
+		return nil, errmodel.BadRequest(ctx, "current password is required", errmodel.FieldViolation("current_password", "current password is required"))
+	}
+
+	if req.NewPassword == "" {
+		return nil, errmodel.BadRequest(ctx, "new password is required", errmodel.FieldViolation("new_password", "new password is required"))
+	}
+
+	count, code, err := s.authSvc.ChangePassword(ctx, bearerTokenFromContext(ctx), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.ChangePasswordResponse{RevokedSessions: count}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) BeginTOTPEnrollment(ctx context.Context, req *identity_v1.BeginTOTPEnrollmentRequest) (*identity_v1.BeginTOTPEnrollmentResponse, error) {
+	resp, code, err := s.authSvc.BeginTOTPEnrollment(ctx, bearerTokenFromContext(ctx))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return resp, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) ConfirmTOTPEnrollment(ctx context.Context, req *identity_v1.ConfirmTOTPEnrollmentRequest) (*identity_v1.ConfirmTOTPEnrollmentResponse, error) {
+	if req == nil || strings.TrimSpace(req.Code) == "" {
+		return nil, errmodel.BadRequest(ctx, "code is required", errmodel.FieldViolation("code", "code is required"))
+	}
+
+	recoveryCodes, code, err := s.authSvc.ConfirmTOTPEnrollment(ctx, bearerTokenFromContext(ctx), req.Code)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.ConfirmTOTPEnrollmentResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) BeginWebAuthnRegistration(ctx context.Context, req *identity_v1.BeginWebAuthnRegistrationRequest) (*identity_v1.BeginWebAuthnRegistrationResponse, error) {
+	options, code, err := s.authSvc.BeginWebAuthnRegistration(ctx, bearerTokenFromContext(ctx))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.BeginWebAuthnRegistrationResponse{OptionsJson: options}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) FinishWebAuthnRegistration(ctx context.Context, req *identity_v1.FinishWebAuthnRegistrationRequest) (*identity_v1.FinishWebAuthnRegistrationResponse, error) {
+	if req == nil || len(req.ClientDataJson) == 0 {
+		return nil, errmodel.BadRequest(ctx, "client data is required", errmodel.FieldViolation("client_data_json", "client data is required"))
+	}
+
+	if len(req.AttestationObject) == 0 {
+		return nil, errmodel.BadRequest(ctx, "attestation object is required", errmodel.FieldViolation("attestation_object", "attestation object is required"))
+	}
+
+	credentialID, code, err := s.authSvc.FinishWebAuthnRegistration(ctx, bearerTokenFromContext(ctx), req.Name, req.ClientDataJson, req.AttestationObject, req.Transports)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.FinishWebAuthnRegistrationResponse{CredentialId: credentialID}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) RegenerateRecoveryCodes(ctx context.Context, req *identity_v1.RegenerateRecoveryCodesRequest) (*identity_v1.RegenerateRecoveryCodesResponse, error) {
+	if req == nil || req.Password == "" {
+		return nil, errmodel.BadRequest(ctx, "password is required", errmodel.FieldViolation("password", "password is required"))
+	}
+
+	recoveryCodes, code, err := s.authSvc.RegenerateRecoveryCodes(ctx, bearerTokenFromContext(ctx), req.Password)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.RegenerateRecoveryCodesResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+// PUBLIC SCOPE
+func (s *GRPCIdentityServer) GetRecoveryCodesStatus(ctx context.Context, req *identity_v1.GetRecoveryCodesStatusRequest) (*identity_v1.GetRecoveryCodesStatusResponse, error) {
+	remaining, total, code, err := s.authSvc.GetRecoveryCodesStatus(ctx, bearerTokenFromContext(ctx))
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return &identity_v1.GetRecoveryCodesStatusResponse{
+		Remaining: int32(remaining),
+		Total:     int32(total),
+	}, nil
 }
 
 // INTERNAL SCOPE
@@ -86,7 +398,7 @@ func (s *GRPCIdentityServer) GetJWKS(ctx context.Context, req *identity_v1.GetJW
 
 	keys, code, err := s.authSvc.GetJWKS(ctx)
 	if err != nil {
-		return nil, errmodel.Error(ctx, code, err.Error())
+		return nil, serviceError(ctx, code, err)
 	}
 
 	return &identity_v1.GetJWKSResponse{Jwks: keys}, nil
@@ -94,17 +406,63 @@ func (s *GRPCIdentityServer) GetJWKS(ctx context.Context, req *identity_v1.GetJW
 
 // INTERNAL SCOPE
 func (s *GRPCIdentityServer) ValidateToken(ctx context.Context, req *identity_v1.ValidateTokenRequest) (*identity_v1.ValidateTokenResponse, error) {
-	return nil, errmodel.Error(ctx, codes.Unimplemented, "validate token is not implemented")
+	if req == nil || strings.TrimSpace(req.Token) == "" {
+		return nil, errmodel.BadRequest(ctx, "token is required", errmodel.FieldViolation("token", "token is required"))
+	}
+
+	resp, code, err := s.authSvc.ValidateToken(ctx, req.Token)
+	if err != nil {
+		return nil, serviceError(ctx, code, err)
+	}
+
+	return resp, nil
 }
 
 // INTERNAL SCOPE
 func (s *GRPCIdentityServer) Authorize(ctx context.Context, req *identity_v1.AuthorizeRequest) (*identity_v1.AuthorizeResponse, error) {
-	return nil, errmodel.Error(ctx, codes.Unimplemented, "authorize is not implemented")
+	if req == nil {
+		return nil, errmodel.BadRequest(ctx, "subject, resource and action are required", errmodel.FieldViolation("subject", "subject is required"))
+	}
+
+	in := authorizeInput{
+		Subject:  strings.TrimSpace(req.Subject),
+		Resource: strings.TrimSpace(req.Resource),
+		Action:   strings.TrimSpace(req.Action),
+	}
+
+	if err := v.Struct(in); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid authorize request", errmodel.FieldViolation("request", err.Error()))
+	}
+
+	resp, code, err := s.policySvc.Authorize(ctx, in.Subject, in.Resource, in.Action)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return resp, nil
 }
 
 // INTERNAL SCOPE
 func (s *GRPCIdentityServer) GetUserBrief(ctx context.Context, req *identity_v1.GetUserBriefRequest) (*identity_v1.GetUserBriefResponse, error) {
-	return nil, errmodel.Error(ctx, codes.Unimplemented, "get user brief is not implemented")
+	if req == nil || len(req.Ids) == 0 {
+		return nil, errmodel.BadRequest(ctx, "ids are required", errmodel.FieldViolation("ids", "ids are required"))
+	}
+
+	in := getUserBriefInput{Ids: make([]string, 0, len(req.Ids))}
+	for _, id := range req.Ids {
+		in.Ids = append(in.Ids, strings.TrimSpace(id))
+	}
+
+	if err := v.Struct(in); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid ids", errmodel.FieldViolation("ids", err.Error()))
+	}
+
+	users, notFound, code, err := s.userBriefSvc.GetUserBriefs(ctx, in.Ids)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.GetUserBriefResponse{Users: users, NotFoundIds: notFound}, nil
 }
 
 // ADMIN SCOPE
@@ -161,6 +519,62 @@ func (s *GRPCIdentityServer) DeleteUser(ctx context.Context, req *identity_v1.De
 	return &identity_v1.DeleteUserResponse{}, nil
 }
 
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) SetUserStatus(ctx context.Context, req *identity_v1.SetUserStatusRequest) (*identity_v1.SetUserStatusResponse, error) {
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return nil, errmodel.BadRequest(ctx, "id is required", errmodel.FieldViolation("id", "id is required"))
+	}
+
+	in := setUserStatusInput{
+		Status: strings.TrimSpace(req.Status),
+		Reason: strings.TrimSpace(req.Reason),
+	}
+
+	if err := v.Struct(in); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid user status", errmodel.FieldViolation("status", err.Error()))
+	}
+
+	update := domain.UserStatusUpdate{
+		Status: domain.UserStatus(in.Status),
+		Reason: in.Reason,
+	}
+
+	if req.SuspendedUntil > 0 {
+		until := time.Unix(req.SuspendedUntil, 0).UTC()
+		update.SuspendedUntil = &until
+	}
+
+	user, code, err := s.adminSvc.SetUserStatus(ctx, strings.TrimSpace(req.Id), update)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.SetUserStatusResponse{User: user}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) DisableUser(ctx context.Context, req *identity_v1.DisableUserRequest) (*identity_v1.DisableUserResponse, error) {
+	if req == nil || strings.TrimSpace(req.Id) == "" {
+		return nil, errmodel.BadRequest(ctx, "id is required", errmodel.FieldViolation("id", "id is required"))
+	}
+
+	if len(req.Reason) > 500 {
+		return nil, errmodel.BadRequest(ctx, "reason is too long", errmodel.FieldViolation("reason", "reason must be at most 500 characters"))
+	}
+
+	update := domain.UserStatusUpdate{
+		Status: domain.UserStatusDisabled,
+		Reason: strings.TrimSpace(req.Reason),
+	}
+
+	_, code, err := s.adminSvc.SetUserStatus(ctx, strings.TrimSpace(req.Id), update)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.DisableUserResponse{}, nil
+}
+
 // ADMIN SCOPE
 func (s *GRPCIdentityServer) ListUsers(ctx context.Context, req *identity_v1.ListUsersRequest) (*identity_v1.ListUsersResponse, error) {
 	users, nextToken, code, err := s.adminSvc.ListUsers(ctx)
@@ -171,6 +585,134 @@ func (s *GRPCIdentityServer) ListUsers(ctx context.Context, req *identity_v1.Lis
 	return &identity_v1.ListUsersResponse{Users: users, NextPageToken: nextToken}, nil
 }
 
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) ListUserSessions(ctx context.Context, req *identity_v1.ListUserSessionsRequest) (*identity_v1.ListUserSessionsResponse, error) {
+	if req == nil || strings.TrimSpace(req.UserId) == "" {
+		return nil, errmodel.BadRequest(ctx, "user id is required", errmodel.FieldViolation("user_id", "user id is required"))
+	}
+
+	sessions, code, err := s.adminSvc.ListUserSessions(ctx, strings.TrimSpace(req.UserId))
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.ListUserSessionsResponse{Sessions: sessions}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) RevokeUserSession(ctx context.Context, req *identity_v1.RevokeUserSessionRequest) (*identity_v1.RevokeUserSessionResponse, error) {
+	if req == nil || strings.TrimSpace(req.UserId) == "" {
+		return nil, errmodel.BadRequest(ctx, "user id is required", errmodel.FieldViolation("user_id", "user id is required"))
+	}
+
+	if strings.TrimSpace(req.SessionId) == "" {
+		return nil, errmodel.BadRequest(ctx, "session id is required", errmodel.FieldViolation("session_id", "session id is required"))
+	}
+
+	code, err := s.adminSvc.RevokeUserSession(ctx, strings.TrimSpace(req.UserId), strings.TrimSpace(req.SessionId))
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.RevokeUserSessionResponse{}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) RevokeUserSessions(ctx context.Context, req *identity_v1.RevokeUserSessionsRequest) (*identity_v1.RevokeUserSessionsResponse, error) {
+	if req == nil || strings.TrimSpace(req.UserId) == "" {
+		return nil, errmodel.BadRequest(ctx, "user id is required", errmodel.FieldViolation("user_id", "user id is required"))
+	}
+
+	count, code, err := s.adminSvc.RevokeUserSessions(ctx, strings.TrimSpace(req.UserId))
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.RevokeUserSessionsResponse{RevokedCount: count}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) ListLoginThrottles(ctx context.Context, req *identity_v1.ListLoginThrottlesRequest) (*identity_v1.ListLoginThrottlesResponse, error) {
+	if req == nil {
+		req = &identity_v1.ListLoginThrottlesRequest{}
+	}
+
+	in := listLoginThrottlesInput{Limit: req.Limit}
+	if err := v.Struct(in); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid limit", errmodel.FieldViolation("limit", err.Error()))
+	}
+
+	if in.Limit == 0 {
+		in.Limit = 100
+	}
+
+	throttles, code, err := s.adminSvc.ListLoginThrottles(ctx, req.LockedOnly, int64(in.Limit))
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.ListLoginThrottlesResponse{Throttles: throttles}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) ClearLoginThrottle(ctx context.Context, req *identity_v1.ClearLoginThrottleRequest) (*identity_v1.ClearLoginThrottleResponse, error) {
+	if req == nil || (strings.TrimSpace(req.Email) == "" && strings.TrimSpace(req.IpAddress) == "") {
+		return nil, errmodel.BadRequest(ctx, "email or ip address is required",
+			errmodel.FieldViolation("email", "email or ip address is required"),
+			errmodel.FieldViolation("ip_address", "email or ip address is required"),
+		)
+	}
+
+	cleared, code, err := s.adminSvc.ClearLoginThrottle(ctx, req.Email, req.IpAddress)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.ClearLoginThrottleResponse{Cleared: cleared}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) ImportUsers(ctx context.Context, req *identity_v1.ImportUsersRequest) (*identity_v1.ImportUsersResponse, error) {
+	if req == nil {
+		req = &identity_v1.ImportUsersRequest{}
+	}
+
+	if err := v.Struct(importUsersInput{Users: len(req.Users)}); err != nil {
+		return nil, errmodel.BadRequest(ctx, "invalid users", errmodel.FieldViolation("users", "between 1 and 500 users are required"))
+	}
+
+	imported, failures, code, err := s.adminSvc.ImportUsers(ctx, req.Users)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.ImportUsersResponse{Imported: imported, Failures: failures}, nil
+}
+
+// ADMIN SCOPE
+func (s *GRPCIdentityServer) GetPasswordHashStats(ctx context.Context, _ *identity_v1.GetPasswordHashStatsRequest) (*identity_v1.GetPasswordHashStatsResponse, error) {
+	count, code, err := s.adminSvc.CountLegacyPasswordHashes(ctx)
+	if err != nil {
+		return nil, errmodel.Error(ctx, code, err.Error())
+	}
+
+	return &identity_v1.GetPasswordHashStatsResponse{LegacyHashes: count}, nil
+}
+
+func bearerTokenFromContext(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			scheme, token, found := strings.Cut(strings.TrimSpace(values[0]), " ")
+
+			if found && strings.EqualFold(scheme, "bearer") {
+				return strings.TrimSpace(token)
+			}
+		}
+	}
+
+	return ""
+}
+
 // TODO: -> core/pkg/logger
 func userAgentFromContext(ctx context.Context) string {
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
@@ -182,17 +724,52 @@ func userAgentFromContext(ctx context.Context) string {
 	return ""
 }
 
-// TODO: -> core/pkg/logger
-func ipFromContext(ctx context.Context) string {
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		if values := md.Get("x-forwarded-for"); len(values) > 0 {
-			return values[0]
+// ipFromContext returns the client address for rate limiting and audit. It is the connection peer
+// unless that peer is a trusted proxy; then x-forwarded-for is walked from the right, skipping
+// trusted hops, and the first untrusted one is the client. Hops further left were written by the
+// client itself and are never used.
+func ipFromContext(ctx context.Context, trustedProxies []netip.Prefix) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	addrPort, err := netip.ParseAddrPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+
+	client := addrPort.Addr().Unmap()
+	if !isTrustedProxy(client, trustedProxies) {
+		return client.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	hops := strings.Split(strings.Join(md.Get("x-forwarded-for"), ","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A trusted proxy writes a valid address, so this came from the client; the last
+			// trusted hop is the closest address we can vouch for.
+			break
 		}
 
-		if values := md.Get("x-real-ip"); len(values) > 0 {
-			return values[0]
+		client = hop.Unmap()
+		if !isTrustedProxy(client, trustedProxies) {
+			break
 		}
 	}
 
-	return ""
+	return client.String()
+}
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestIPFromContext(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("2001:db8::/32")}
+
+	tests := []struct {
+		name    string
+		peer    net.Addr
+		xff     []string
+		realIP  string
+		trusted []netip.Prefix
+		want    string
+	}{
+		{name: "no peer", want: ""},
+		{name: "direct client", peer: tcpAddr("198.51.100.7"), want: "198.51.100.7"},
+		{name: "direct client forging headers", peer: tcpAddr("198.51.100.7"), xff: []string{"203.0.113.1"}, realIP: "203.0.113.2", trusted: trusted, want: "198.51.100.7"},
+		{name: "headers ignored without trusted proxies", peer: tcpAddr("10.0.0.1"), xff: []string{"203.0.113.1"}, want: "10.0.0.1"},
+		{name: "x-real-ip is never trusted", peer: tcpAddr("10.0.0.1"), realIP: "203.0.113.2", trusted: trusted, want: "10.0.0.1"},
+		{name: "trusted proxy", peer: tcpAddr("10.0.0.1"), xff: []string{"203.0.113.1"}, trusted: trusted, want: "203.0.113.1"},
+		{name: "client prepends a forged hop", peer: tcpAddr("10.0.0.1"), xff: []string{"192.0.2.66, 203.0.113.1"}, trusted: trusted, want: "203.0.113.1"},
+		{name: "chain of trusted proxies", peer: tcpAddr("10.0.0.1"), xff: []string{"192.0.2.66, 203.0.113.1, 10.1.2.3"}, trusted: trusted, want: "203.0.113.1"},
+		{name: "header split over several values", peer: tcpAddr("10.0.0.1"), xff: []string{"192.0.2.66", "203.0.113.1, 10.1.2.3"}, trusted: trusted, want: "203.0.113.1"},
+		{name: "garbage stops at the last trusted hop", peer: tcpAddr("10.0.0.1"), xff: []string{"not-an-ip, 10.1.2.3"}, trusted: trusted, want: "10.1.2.3"},
+		{name: "trusted proxy without the header", peer: tcpAddr("10.0.0.1"), trusted: trusted, want: "10.0.0.1"},
+		{name: "ipv6", peer: tcpAddr("2001:db8::1"), xff: []string{"2001:db9::5"}, trusted: trusted, want: "2001:db9::5"},
+		{name: "ipv4 mapped peer", peer: tcpAddr("::ffff:10.0.0.1"), xff: []string{"203.0.113.1"}, trusted: trusted, want: "203.0.113.1"},
+		{name: "non tcp peer", peer: &net.UnixAddr{Name: "/run/identity.sock", Net: "unix"}, trusted: trusted, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.peer != nil {
+				ctx = peer.NewContext(ctx, &peer.Peer{Addr: tt.peer})
+			}
+
+			md := metadata.MD{}
+			for _, value := range tt.xff {
+				md.Append("x-forwarded-for", value)
+			}
+
+			if tt.realIP != "" {
+				md.Set("x-real-ip", tt.realIP)
+			}
+
+			ctx = metadata.NewIncomingContext(ctx, md)
+
+			if got := ipFromContext(ctx, tt.trusted); got != tt.want {
+				t.Fatalf("ipFromContext = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func tcpAddr(ip string) *net.TCPAddr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 41234}
+}
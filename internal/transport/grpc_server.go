@@ -3,6 +3,7 @@ package transport
 import (
 	"fmt"
 	"net"
+	"net/netip"
 
 	"github.com/invenlore/core/pkg/config"
 	"github.com/invenlore/core/pkg/db"
@@ -17,20 +18,26 @@ import (
 type GRPCIdentityServer struct {
 	adminSvc       service.IdentityAdminService
 	authSvc        service.IdentityAuthService
+	policySvc      service.IdentityPolicyService
+	userBriefSvc   service.IdentityUserBriefService
 	mongoReadiness *db.MongoReadiness
+	trustedProxies []netip.Prefix
 	identity_v1.UnimplementedIdentityPublicServiceServer
 	identity_v1.UnimplementedIdentityInternalServiceServer
 }
 
-func NewGRPCIdentityServer(adminSvc service.IdentityAdminService, authSvc service.IdentityAuthService, mongoReadiness *db.MongoReadiness) *GRPCIdentityServer {
+func NewGRPCIdentityServer(adminSvc service.IdentityAdminService, authSvc service.IdentityAuthService, policySvc service.IdentityPolicyService, userBriefSvc service.IdentityUserBriefService, mongoReadiness *db.MongoReadiness, trustedProxies []netip.Prefix) *GRPCIdentityServer {
 	return &GRPCIdentityServer{
 		adminSvc:       adminSvc,
 		authSvc:        authSvc,
+		policySvc:      policySvc,
+		userBriefSvc:   userBriefSvc,
 		mongoReadiness: mongoReadiness,
+		trustedProxies: trustedProxies,
 	}
 }
 
-func StartGRPCServer(cfg *config.GRPCServerConfig, adminSvc service.IdentityAdminService, authSvc service.IdentityAuthService, mongoReadiness *db.MongoReadiness) (*grpc.Server, net.Listener, error) {
+func StartGRPCServer(cfg *config.GRPCServerConfig, adminSvc service.IdentityAdminService, authSvc service.IdentityAuthService, policySvc service.IdentityPolicyService, userBriefSvc service.IdentityUserBriefService, mongoReadiness *db.MongoReadiness, trustedProxies []netip.Prefix) (*grpc.Server, net.Listener, error) {
 	var (
 		loggerEntry = logrus.WithField("scope", "grpcServer")
 		listenAddr  = net.JoinHostPort(cfg.Host, cfg.Port)
@@ -62,7 +69,7 @@ func StartGRPCServer(cfg *config.GRPCServerConfig, adminSvc service.IdentityAdmi
 		grpc.ChainStreamInterceptor(streamInterceptors...),
 	)
 
-	grpcServer := NewGRPCIdentityServer(adminSvc, authSvc, mongoReadiness)
+	grpcServer := NewGRPCIdentityServer(adminSvc, authSvc, policySvc, userBriefSvc, mongoReadiness, trustedProxies)
 	identity_v1.RegisterIdentityPublicServiceServer(server, grpcServer)
 	identity_v1.RegisterIdentityInternalServiceServer(server, grpcServer)
 
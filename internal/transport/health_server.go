@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"expvar"
 	"fmt"
 	"net"
 	"net/http"
@@ -25,6 +26,8 @@ func StartHealthServer(cfg *config.HealthServerConfig) (*http.Server, net.Listen
 
 	mux := http.NewServeMux()
 	mux.Handle("GET /health", health.GetHealthHandler())
+	// Published by the service packages, e.g. password_hashing; kept off the public gRPC port.
+	mux.Handle("GET /debug/vars", expvar.Handler())
 
 	server := &http.Server{
 		Addr:              listenAddr,
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"github.com/invenlore/identity.service/cmd"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rewrap-keys" {
+		cmd.RewrapKeys()
+		return
+	}
+
 	cmd.Start()
 }